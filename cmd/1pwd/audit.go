@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+	"github.com/mattdenner/1pwd/pkg/passgen"
+)
+
+type auditOptions struct {
+	format     string
+	minLength  int
+	minEntropy float64
+	maxAge     int
+	otpDomains []string
+}
+
+// The issues reported by doAudit, in the order they are printed.
+var auditIssues = []string{"reused", "weak", "old", "no-otp", "http"}
+
+type auditFinding struct {
+	Issue  string `json:"issue"`
+	UUID   string `json:"uuid"`
+	Title  string `json:"title"`
+	Folder string `json:"folder,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// otpDomains are sites which offer one-time passwords, so logins for them
+// are expected to have one.
+var otpDomains = []string{
+	"amazon.com", "apple.com", "atlassian.com", "azure.com", "bitbucket.org",
+	"cloudflare.com", "coinbase.com", "digitalocean.com", "discord.com",
+	"docker.com", "dropbox.com", "facebook.com", "github.com", "gitlab.com",
+	"godaddy.com", "google.com", "heroku.com", "instagram.com", "linkedin.com",
+	"linode.com", "live.com", "microsoft.com", "namecheap.com", "netlify.com",
+	"npmjs.com", "okta.com", "paypal.com", "pypi.org", "reddit.com",
+	"salesforce.com", "sentry.io", "slack.com", "stripe.com", "twitch.tv",
+	"twitter.com", "vercel.com", "x.com", "zoom.us",
+}
+
+// doAudit decrypts the items matching the query and reports reused, weak
+// and old passwords, logins without a one-time password for sites that
+// offer them and URLs which do not use HTTPS.
+func doAudit(vault *opvault.Vault, query string, opts *auditOptions) {
+	q, err := opvault.ParseQuery(query)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	items, err := vault.Search(q)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	var (
+		findings  []*auditFinding
+		passwords = map[string][]*opvault.Item{}
+		withOTP   = map[string]bool{}
+		now       = time.Now()
+	)
+
+	report := func(item *opvault.Item, issue, detail string) {
+		f := &auditFinding{Issue: issue, UUID: item.UUID, Title: item.Data.Title, Detail: detail}
+		if folder := vault.FolderOf(item); folder != nil {
+			f.Folder = folder.Path()
+		}
+		findings = append(findings, f)
+	}
+
+	for _, item := range items {
+		err := item.Decrypt(vault)
+		assert(err)
+
+		if otps, err := item.OTPs(); err == nil && len(otps) > 0 {
+			for _, host := range itemHosts(item) {
+				withOTP[host] = true
+			}
+		}
+
+		for _, u := range item.URLs() {
+			if pu, err := url.Parse(u); err == nil && pu.Scheme == "http" && !isLoopback(pu.Hostname()) {
+				report(item, "http", u)
+			}
+		}
+
+		password, found := item.Extract("password")
+		if !found || password == "" {
+			continue
+		}
+
+		passwords[password] = append(passwords[password], item)
+
+		length := len([]rune(password))
+		entropy := passgen.Estimate(password)
+		if length < opts.minLength || entropy < opts.minEntropy {
+			report(item, "weak", fmt.Sprintf("%d characters, about %.0f bits of entropy", length, entropy))
+		}
+
+		if changed := item.PasswordChanged(); opts.maxAge > 0 && now.Sub(changed) > time.Duration(opts.maxAge)*24*time.Hour {
+			report(item, "old", fmt.Sprintf("unchanged since %s (%d days)", changed.Format("2006-01-02"), int(now.Sub(changed).Hours()/24)))
+		}
+	}
+
+	for _, same := range passwords {
+		if len(same) < 2 {
+			continue
+		}
+		for _, item := range same {
+			var others []string
+			for _, other := range same {
+				if other != item {
+					others = append(others, other.Data.Title)
+				}
+			}
+			report(item, "reused", "same password as "+strings.Join(others, ", "))
+		}
+	}
+
+	// Logins for sites offering one-time passwords, or for which another
+	// item has one, should have one too.
+	expected := append(append([]string(nil), otpDomains...), opts.otpDomains...)
+	for _, item := range items {
+		if item.Category != opvault.LoginItem {
+			continue
+		}
+		if password, _ := item.Extract("password"); password == "" {
+			continue
+		}
+		if otps, err := item.OTPs(); err != nil || len(otps) > 0 {
+			continue
+		}
+
+		for _, host := range itemHosts(item) {
+			if d := matchDomain(host, expected); d != "" {
+				report(item, "no-otp", d+" offers one-time passwords")
+				break
+			}
+			if withOTP[host] {
+				report(item, "no-otp", "other entries for "+host+" have a one-time password")
+				break
+			}
+		}
+	}
+
+	order := map[string]int{}
+	for i, issue := range auditIssues {
+		order[issue] = i
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Issue != b.Issue {
+			return order[a.Issue] < order[b.Issue]
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+
+	switch opts.format {
+	case "json":
+		if findings == nil {
+			findings = []*auditFinding{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		assert(enc.Encode(findings))
+
+	default:
+		tabw := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+		for _, f := range findings {
+			fmt.Fprintf(tabw, "%s\t%s\t%s\t%s\n", f.Issue, f.UUID, f.Title, f.Detail)
+		}
+		tabw.Flush()
+
+		fmt.Fprintf(os.Stderr, "%d issues in %d entries\n", len(findings), len(items))
+	}
+}
+
+// itemHosts returns the host names of the URLs of an item, without "www.".
+func itemHosts(item *opvault.Item) []string {
+	var hosts []string
+	for _, u := range item.URLs() {
+		if !strings.Contains(u, "://") {
+			u = "https://" + u
+		}
+		pu, err := url.Parse(u)
+		if err != nil || pu.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, strings.TrimPrefix(strings.ToLower(pu.Hostname()), "www."))
+	}
+	return hosts
+}
+
+// matchDomain returns the domain of which host is (a sub domain) or "".
+func matchDomain(host string, domains []string) string {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || strings.HasPrefix(host, "127.") || host == "::1"
+}
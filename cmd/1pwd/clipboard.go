@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mattdenner/1pwd/pkg/clipboard"
+)
+
+// copyToClipboard puts value on the clipboard. When clearAfter is set a
+// background process clears the clipboard after that time, unless its
+// contents were replaced in the mean time.
+func copyToClipboard(backend, what, value string, clearAfter time.Duration) {
+	b, err := clipboard.Lookup(backend)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	err = b.Copy([]byte(value))
+	assert(err)
+
+	if clearAfter <= 0 {
+		fmt.Fprintf(os.Stderr, "copied %s to the clipboard\n", what)
+		return
+	}
+
+	if _, err := b.Paste(); err == clipboard.ErrUnsupported {
+		fmt.Fprintf(os.Stderr, "copied %s to the clipboard (%s cannot be cleared automatically)\n", what, b.Name())
+		return
+	}
+
+	exe, err := os.Executable()
+	assert(err)
+
+	sum := sha256.Sum256([]byte(value))
+
+	// The checksum is passed on stdin so it does not show up in the
+	// process list.
+	cmd := exec.Command(exe, "clipboard-clear", "--clipboard="+b.Name(), "--after="+clearAfter.String())
+	stdin, err := cmd.StdinPipe()
+	assert(err)
+	err = cmd.Start()
+	assert(err)
+	_, err = io.WriteString(stdin, hex.EncodeToString(sum[:]))
+	assert(err)
+	assert(stdin.Close())
+
+	fmt.Fprintf(os.Stderr, "copied %s to the clipboard, clearing it in %s\n", what, clearAfter)
+}
+
+// doClipboardClear waits and then clears the clipboard when it still holds
+// the contents with the SHA-256 sum read from stdin.
+func doClipboardClear(backend string, after time.Duration) {
+	// Keep going when the terminal that started us is closed.
+	signal.Ignore(syscall.SIGHUP)
+
+	data, err := ioutil.ReadAll(os.Stdin)
+	assert(err)
+
+	sum, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	assert(err)
+
+	b, err := clipboard.Lookup(backend)
+	assert(err)
+
+	time.Sleep(after)
+
+	clipboard.ClearIf(b, sum)
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// findBuiltin is a Finder that runs in the terminal itself, so no external
+// fuzzy finder has to be installed. Only the overview data of the items
+// (which is already decrypted) is ever displayed.
+func findBuiltin(vault *opvault.Vault, query string, items []*opvault.Item) (*opvault.Item, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("the builtin finder needs a terminal: %s", err)
+	}
+	defer tty.Close()
+
+	state, err := stty(tty, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("the builtin finder needs a terminal: %s", err)
+	}
+	_, err = stty(tty, "raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+	defer stty(tty, state)
+
+	f := &fuzzyFinder{
+		vault: vault,
+		items: items,
+		query: []rune(query),
+		out:   bufio.NewWriter(tty),
+		rows:  24,
+		cols:  80,
+	}
+
+	if size, err := stty(tty, "size"); err == nil {
+		var rows, cols int
+		fmt.Sscan(size, &rows, &cols)
+		if rows > 0 && cols > 0 {
+			f.rows, f.cols = rows, cols
+		}
+	}
+
+	fmt.Fprint(f.out, "\x1b[?1049h")
+	defer func() {
+		fmt.Fprint(f.out, "\x1b[?1049l")
+		f.out.Flush()
+	}()
+
+	return f.run(tty)
+}
+
+// stty runs stty on the terminal and returns its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+type fuzzyFinder struct {
+	vault   *opvault.Vault
+	items   []*opvault.Item
+	matches []*opvault.Item
+	query   []rune
+	cursor  int
+	offset  int
+	rows    int
+	cols    int
+	out     *bufio.Writer
+}
+
+const previewLines = 7
+
+func (f *fuzzyFinder) run(tty *os.File) (*opvault.Item, error) {
+	var buf [64]byte
+
+	f.filter()
+	for {
+		f.draw()
+
+		n, err := tty.Read(buf[:])
+		if err != nil {
+			return nil, err
+		}
+		in := buf[:n]
+
+		if len(in) == 1 && in[0] == 27 {
+			return nil, nil
+		}
+
+		if len(in) >= 3 && in[0] == 27 && (in[1] == '[' || in[1] == 'O') {
+			switch string(in[2:]) {
+			case "A":
+				f.move(-1)
+			case "B":
+				f.move(1)
+			case "5~":
+				f.move(-f.listHeight())
+			case "6~":
+				f.move(f.listHeight())
+			}
+			continue
+		}
+
+		changed := false
+		for len(in) > 0 {
+			r, size := utf8.DecodeRune(in)
+			in = in[size:]
+
+			switch {
+			case r == 3 || r == 7: // ^C, ^G
+				return nil, nil
+			case r == '\r' || r == '\n':
+				if len(f.matches) == 0 {
+					return nil, nil
+				}
+				return f.matches[f.cursor], nil
+			case r == 16: // ^P
+				f.move(-1)
+			case r == 14: // ^N
+				f.move(1)
+			case r == 127 || r == 8: // backspace, ^H
+				if len(f.query) > 0 {
+					f.query = f.query[:len(f.query)-1]
+					changed = true
+				}
+			case r == 21: // ^U
+				f.query = f.query[:0]
+				changed = true
+			case r == 23: // ^W
+				i := len(f.query)
+				for i > 0 && f.query[i-1] == ' ' {
+					i--
+				}
+				for i > 0 && f.query[i-1] != ' ' {
+					i--
+				}
+				f.query = f.query[:i]
+				changed = true
+			case r >= ' ' && r != utf8.RuneError:
+				f.query = append(f.query, r)
+				changed = true
+			}
+		}
+
+		if changed {
+			f.filter()
+		}
+	}
+}
+
+func (f *fuzzyFinder) move(delta int) {
+	f.cursor += delta
+	if f.cursor >= len(f.matches) {
+		f.cursor = len(f.matches) - 1
+	}
+	if f.cursor < 0 {
+		f.cursor = 0
+	}
+}
+
+func (f *fuzzyFinder) showPreview() bool {
+	return f.rows >= 2+previewLines+5
+}
+
+func (f *fuzzyFinder) listHeight() int {
+	h := f.rows - 2
+	if f.showPreview() {
+		h -= previewLines
+	}
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+// filter scores all the items against the query. Every space separated
+// term has to match the title, domain or category of an item.
+func (f *fuzzyFinder) filter() {
+	f.cursor = 0
+	f.offset = 0
+
+	var terms [][]rune
+	for _, term := range strings.Fields(strings.ToLower(string(f.query))) {
+		terms = append(terms, []rune(term))
+	}
+
+	if len(terms) == 0 {
+		f.matches = f.items
+		return
+	}
+
+	var scored scoredItems
+	for _, item := range f.items {
+		if score, ok := scoreItem(terms, item); ok {
+			scored = append(scored, scoredItem{item, score})
+		}
+	}
+	sort.Stable(scored)
+
+	f.matches = make([]*opvault.Item, len(scored))
+	for i, s := range scored {
+		f.matches[i] = s.item
+	}
+}
+
+func (f *fuzzyFinder) draw() {
+	height := f.listHeight()
+	if f.cursor < f.offset {
+		f.offset = f.cursor
+	}
+	if f.cursor >= f.offset+height {
+		f.offset = f.cursor - height + 1
+	}
+
+	f.line(1, "> "+string(f.query), "")
+	f.line(2, fmt.Sprintf("  %d/%d", len(f.matches), len(f.items)), "2")
+
+	titleWidth := (f.cols - 2) / 2
+	domainWidth := (f.cols - 2) / 4
+	for i := 0; i < height; i++ {
+		idx := f.offset + i
+		if idx >= len(f.matches) {
+			f.line(3+i, "", "")
+			continue
+		}
+
+		item := f.matches[idx]
+		text := fit(item.Data.Title, titleWidth) + " " +
+			fit(item.Data.Domain, domainWidth) + " " +
+			item.Category.String()
+
+		if idx == f.cursor {
+			f.line(3+i, "> "+text, "7")
+		} else {
+			f.line(3+i, "  "+text, "")
+		}
+	}
+
+	if f.showPreview() {
+		row := 3 + height
+		f.line(row, strings.Repeat("─", f.cols), "2")
+		for i, text := range f.preview() {
+			f.line(row+1+i, text, "")
+		}
+	}
+
+	fmt.Fprintf(f.out, "\x1b[1;%dH", 3+len(f.query))
+	f.out.Flush()
+}
+
+// preview describes the selected item using only its overview data.
+func (f *fuzzyFinder) preview() []string {
+	lines := make([]string, previewLines-1)
+	if len(f.matches) == 0 {
+		return lines
+	}
+
+	item := f.matches[f.cursor]
+
+	folder := ""
+	if fo := f.vault.FolderOf(item); fo != nil {
+		folder = fo.Path()
+	}
+
+	updated := ""
+	if item.Updated > 0 {
+		updated = time.Unix(item.Updated, 0).Format("2006-01-02 15:04")
+	}
+
+	lines[0] = "Title     " + item.Data.Title
+	lines[1] = "Category  " + item.Category.String()
+	lines[2] = "URL       " + item.Data.URL
+	lines[3] = "Folder    " + folder
+	lines[4] = "Tags      " + strings.Join(item.Data.Tags, ", ")
+	lines[5] = "Updated   " + updated + "  (" + item.UUID + ")"
+
+	return lines
+}
+
+// line draws text on a row of the screen, cut to the width of the terminal
+// and using the SGR attributes in attr.
+func (f *fuzzyFinder) line(row int, text, attr string) {
+	fmt.Fprintf(f.out, "\x1b[%d;1H", row)
+	if attr != "" {
+		fmt.Fprintf(f.out, "\x1b[%sm", attr)
+	}
+	f.out.WriteString(cut(text, f.cols))
+	if attr != "" {
+		f.out.WriteString("\x1b[0m")
+	}
+	f.out.WriteString("\x1b[K")
+}
+
+type scoredItem struct {
+	item  *opvault.Item
+	score int
+}
+
+type scoredItems []scoredItem
+
+func (s scoredItems) Len() int           { return len(s) }
+func (s scoredItems) Less(i, j int) bool { return s[i].score > s[j].score }
+func (s scoredItems) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// scoreItem adds the best score of every term over the title, domain and
+// category of the item. Matches in the title are preferred.
+func scoreItem(terms [][]rune, item *opvault.Item) (int, bool) {
+	fields := [][]rune{
+		[]rune(strings.ToLower(item.Data.Title)),
+		[]rune(strings.ToLower(item.Data.Domain)),
+		[]rune(strings.ToLower(item.Category.String())),
+	}
+
+	total := 0
+	for _, term := range terms {
+		best, found := 0, false
+		for i, field := range fields {
+			score, ok := fuzzyScore(term, field)
+			if !ok {
+				continue
+			}
+			if i == 0 {
+				score += 2
+			}
+			if !found || score > best {
+				best, found = score, true
+			}
+		}
+		if !found {
+			return 0, false
+		}
+		total += best
+	}
+
+	return total, true
+}
+
+// fuzzyScore returns how well pattern matches s as a subsequence and whether
+// it matches at all. Consecutive characters and characters at the start of a
+// word score higher, gaps between characters score lower.
+func fuzzyScore(pattern, s []rune) (int, bool) {
+	if len(pattern) == 0 {
+		return 0, true
+	}
+
+	best, found := 0, false
+	for start := range s {
+		if s[start] != pattern[0] {
+			continue
+		}
+
+		score, p, last := 0, 0, -1
+		for i := start; i < len(s) && p < len(pattern); i++ {
+			if s[i] != pattern[p] {
+				continue
+			}
+
+			score++
+			if last >= 0 && i == last+1 {
+				score += 4
+			} else if last >= 0 {
+				gap := i - last - 1
+				if gap > 3 {
+					gap = 3
+				}
+				score -= gap
+			}
+			if i == 0 || !unicode.IsLetter(s[i-1]) && !unicode.IsDigit(s[i-1]) {
+				score += 3
+			}
+
+			last = i
+			p++
+		}
+
+		if p == len(pattern) && (!found || score > best) {
+			best, found = score, true
+		}
+	}
+
+	return best, found
+}
+
+// fit pads or cuts s to exactly width characters.
+func fit(s string, width int) string {
+	s = cut(s, width)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// cut shortens s to at most width characters.
+func cut(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width == 1 {
+		return string(r[:1])
+	}
+	return string(r[:width-1]) + "…"
+}
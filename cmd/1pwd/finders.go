@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// A Finder lets the user pick one of the items (starting with query). It
+// returns nil when nothing was selected.
+type Finder func(vault *opvault.Vault, query string, items []*opvault.Item) (*opvault.Item, error)
+
+// FinderConfig is the contents of the finder configuration file:
+//
+//	{
+//	  "default": "sk",
+//	  "finders": {
+//	    "sk": {"command": "sk", "args": ["--ansi", "--query={query}"], "color": true},
+//	    "rofi": {"command": "rofi", "args": ["-dmenu", "-i", "-format", "i"],
+//	             "columns": ["title", "domain"], "output": "index"}
+//	  }
+//	}
+type FinderConfig struct {
+	Default string                     `json:"default"`
+	Finders map[string]*ExternalFinder `json:"finders"`
+}
+
+// ExternalFinder describes how to run an external program as Finder.
+type ExternalFinder struct {
+	// Command and Args are executed (without a shell). "{query}" in the
+	// arguments is replaced by the initial query.
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+
+	// Columns lists the columns of every line written to the finder; one of
+	// uuid, short (the first 8 characters of the uuid), category, domain,
+	// title, url, folder and tags. Defaults to uuid, short, category,
+	// domain and title.
+	Columns []string `json:"columns"`
+
+	// Separator is written between the columns. When empty the columns are
+	// aligned with spaces.
+	Separator string `json:"separator"`
+
+	// Color enables ANSI colors in the lines.
+	Color bool `json:"color"`
+
+	// Output is either "line" when the finder prints the selected line (the
+	// default) or "index" when it prints the (0 based) line number.
+	Output string `json:"output"`
+}
+
+var finderColumns = map[string]string{
+	"uuid":     "",
+	"short":    "2",
+	"category": "",
+	"domain":   "blue",
+	"title":    "yellow",
+	"url":      "",
+	"folder":   "",
+	"tags":     "",
+}
+
+var defaultColumns = []string{"uuid", "short", "category", "domain", "title"}
+
+var defaultFinders = map[string]*ExternalFinder{
+	"fzy": {
+		Command: "fzy",
+		Args:    []string{"--query={query}"},
+		Color:   true,
+	},
+	"fzf": {
+		Command: "fzf",
+		Args:    []string{"--ansi", "--with-nth=2..", "--nth=4..,3,1", "--query={query}"},
+		Color:   true,
+	},
+}
+
+// DefaultFinderConfig returns the path of the finder configuration file.
+func DefaultFinderConfig() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "1pwd", "finders.json")
+}
+
+// FinderFor returns the named finder. Finders defined in the configuration
+// file at configPath (which does not have to exist) take precedence over the
+// builtin ones. An empty name selects the default finder of the
+// configuration, or the builtin finder.
+func FinderFor(name, configPath string) (Finder, error) {
+	config, err := loadFinderConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = config.Default
+	}
+	if name == "" {
+		name = "builtin"
+	}
+
+	if finder := config.Finders[name]; finder != nil {
+		return finder.Find, finder.validate(name)
+	}
+	if name == "builtin" {
+		return findBuiltin, nil
+	}
+	if finder := defaultFinders[name]; finder != nil {
+		return finder.Find, nil
+	}
+
+	return nil, fmt.Errorf("unknown finder %q", name)
+}
+
+func loadFinderConfig(path string) (*FinderConfig, error) {
+	var config FinderConfig
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return &config, nil
+}
+
+func (f *ExternalFinder) validate(name string) error {
+	if f.Command == "" {
+		return fmt.Errorf("finder %q has no command", name)
+	}
+	for _, column := range f.Columns {
+		if _, found := finderColumns[column]; !found {
+			return fmt.Errorf("finder %q: unknown column %q", name, column)
+		}
+	}
+	if f.Output != "" && f.Output != "line" && f.Output != "index" {
+		return fmt.Errorf("finder %q: unknown output %q", name, f.Output)
+	}
+	return nil
+}
+
+// Find feeds one line per item to the finder and maps the selection back to
+// its item.
+func (f *ExternalFinder) Find(vault *opvault.Vault, query string, items []*opvault.Item) (*opvault.Item, error) {
+	var bufIn bytes.Buffer
+	var bufOut bytes.Buffer
+
+	lines := f.lines(vault, items)
+	for _, line := range lines {
+		bufIn.WriteString(line)
+		bufIn.WriteByte('\n')
+	}
+
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = strings.Replace(arg, "{query}", query, -1)
+	}
+
+	cmd := exec.Command(f.Command, args...)
+	cmd.Env = os.Environ()
+	cmd.Stdin = &bufIn
+	cmd.Stdout = &bufOut
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	selection := strings.TrimRight(bufOut.String(), "\r\n")
+	if i := strings.IndexByte(selection, '\n'); i >= 0 {
+		selection = selection[:i]
+	}
+	if selection == "" {
+		return nil, nil
+	}
+
+	if f.Output == "index" {
+		idx, err := strconv.Atoi(strings.TrimSpace(selection))
+		if err != nil || idx < 0 || idx >= len(items) {
+			return nil, fmt.Errorf("invalid selection %q", selection)
+		}
+		return items[idx], nil
+	}
+
+	selection = stripANSI(selection)
+	for i, line := range lines {
+		if stripANSI(line) == selection {
+			return items[i], nil
+		}
+	}
+
+	// Finders may change the selected line (fzf --with-nth for example) so
+	// fall back to looking for the uuid of an item.
+	for _, word := range strings.Fields(selection) {
+		for _, item := range items {
+			if item.UUID == word {
+				return item, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("invalid selection %q", selection)
+}
+
+func (f *ExternalFinder) lines(vault *opvault.Vault, items []*opvault.Item) []string {
+	columns := f.Columns
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+
+	tabw := tabwriter.NewWriter(&buf, 8, 8, 2, ' ', tabwriter.StripEscape)
+	if f.Separator == "" {
+		w = tabw
+	}
+
+	for _, item := range items {
+		for i, column := range columns {
+			color := ""
+			if f.Color {
+				color = finderColumns[column]
+			}
+
+			format := field("%s", color)
+			if f.Separator != "" {
+				format = strings.TrimSuffix(format, "\t")
+				if i > 0 {
+					fmt.Fprint(w, f.Separator)
+				}
+			}
+
+			fmt.Fprintf(w, format, columnValue(vault, item, column))
+		}
+		fmt.Fprint(w, "\n")
+	}
+	tabw.Flush()
+
+	var lines []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines
+}
+
+func columnValue(vault *opvault.Vault, item *opvault.Item, column string) string {
+	switch column {
+	case "uuid":
+		return item.UUID
+	case "short":
+		return item.UUID[:8]
+	case "category":
+		return item.Category.String()
+	case "domain":
+		return trunc(item.Data.Domain, 32)
+	case "title":
+		return item.Data.Title
+	case "url":
+		return item.Data.URL
+	case "folder":
+		if folder := vault.FolderOf(item); folder != nil {
+			return folder.Path()
+		}
+		return ""
+	case "tags":
+		return strings.Join(item.Data.Tags, ",")
+	}
+	return ""
+}
+
+var ansiRegexp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func stripANSI(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/alecthomas/template"
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+type namedValue struct {
+	name  string
+	value string
+}
+
+// itemValues returns the values of an item under names that are the same
+// for every category: title, url, username, password, notes and totp come
+// first, followed by the other fields (named after their titles).
+func itemValues(item *opvault.Item) []namedValue {
+	var (
+		values []namedValue
+		seen   = map[string]bool{}
+	)
+
+	add := func(field, value string) {
+		name := valueName(field)
+		if name == "" || value == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		values = append(values, namedValue{name, value})
+	}
+
+	add("title", item.Data.Title)
+	for _, field := range []string{"url", "username", "password", "notes", "One-Time Password"} {
+		if v, f := item.Extract(field); f {
+			add(field, displayFieldValue(field, v))
+		}
+	}
+
+	for _, f := range item.Data.Fields {
+		if f.Type == "C" || f.Type == "B" {
+			continue
+		}
+		add(f.Name, f.Value)
+	}
+
+	for _, s := range item.Data.Sections {
+		for _, f := range s.Fields {
+			name := f.Name
+			if name == "" {
+				name = f.ID
+			}
+			if f.IsOTP() {
+				add("One-Time Password", displayFieldValue("One-Time Password", f.Value))
+				continue
+			}
+			add(name, f.Value)
+		}
+	}
+
+	return values
+}
+
+const masked = "********"
+
+// printTable prints all the fields of an item, grouped by section, in the
+// order in which they are stored. Concealed values are masked unless reveal
+// is set.
+func printTable(item *opvault.Item, reveal bool) {
+	tabw := tabwriter.NewWriter(os.Stdout, 10, 4, 1, ' ', 0)
+
+	conceal := func(v string) string {
+		if reveal {
+			return v
+		}
+		return masked
+	}
+
+	row := func(indent, label, value string) {
+		if value == "" {
+			return
+		}
+		value = strings.Replace(value, "\n", "\n"+indent+"\t", -1)
+		fmt.Fprintf(tabw, "%s%s:\t%s\n", indent, label, value)
+	}
+
+	row("", "id", item.UUID)
+	row("", "title", item.Data.Title)
+	row("", "type", item.Category.String())
+	for _, u := range item.URLs() {
+		row("", "url", u)
+	}
+
+	for _, f := range item.Data.Fields {
+		label := f.Designation
+		if label == "" {
+			label = f.Name
+		}
+		switch f.Type {
+		case "C", "B":
+		case "P":
+			row("", label, conceal(f.Value))
+		default:
+			row("", label, f.Value)
+		}
+	}
+
+	if item.Data.Password != "" {
+		row("", "password", conceal(item.Data.Password))
+	}
+
+	if len(item.Data.Tags) > 0 {
+		row("", "tags", strings.Join(item.Data.Tags, ", "))
+	}
+
+	for _, s := range item.Data.Sections {
+		var fields []opvault.SectionField
+		for _, f := range s.Fields {
+			if f.Value != "" {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		fmt.Fprintln(tabw)
+		indent := ""
+		if s.Title != "" {
+			fmt.Fprintf(tabw, "%s\n", s.Title)
+			indent = "  "
+		}
+
+		for _, f := range fields {
+			label := f.Name
+			if label == "" && f.IsOTP() {
+				label = "one-time password"
+			}
+			if label == "" {
+				label = f.ID
+			}
+
+			switch {
+			case f.IsOTP():
+				row(indent, label, displayFieldValue("One-Time Password", f.Value))
+			case f.Kind == "concealed":
+				row(indent, label, conceal(f.Value))
+			default:
+				row(indent, label, f.Value)
+			}
+		}
+	}
+
+	if item.Data.NotesPlain != "" {
+		fmt.Fprintln(tabw)
+		row("", "notes", item.Data.NotesPlain)
+	}
+
+	tabw.Flush()
+}
+
+// valueName turns the name of a field into a lower case identifier.
+func valueName(field string) string {
+	if field == "One-Time Password" {
+		return "totp"
+	}
+
+	var buf bytes.Buffer
+	for _, r := range strings.ToLower(field) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			buf.WriteRune(r)
+		case buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '_':
+			buf.WriteByte('_')
+		}
+	}
+
+	name := strings.TrimRight(buf.String(), "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// validFormat reports whether format can be passed to doGet.
+func validFormat(format string) bool {
+	switch format {
+	case "table", "json", "yaml", "env", "dotenv", "shell":
+		return true
+	}
+	return strings.HasPrefix(format, "template=")
+}
+
+// validEnvName reports whether name can be used as the name of an
+// environment variable in a shell.
+func validEnvName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// reservedEnvNames are variables which change how the shell, the dynamic
+// loader or common tools behave. Fields named like them ("path", "home")
+// are not exported without a prefix.
+var reservedEnvNames = map[string]bool{
+	"BASH_ENV": true, "CDPATH": true, "ENV": true, "HOME": true, "IFS": true,
+	"LANG": true, "MAIL": true, "OLDPWD": true, "PATH": true, "PROMPT_COMMAND": true,
+	"PS1": true, "PS2": true, "PS4": true, "PWD": true, "SHELL": true,
+	"SHELLOPTS": true, "SSH_AUTH_SOCK": true, "TERM": true, "TMPDIR": true,
+	"TZ": true, "USER": true,
+}
+
+// reservedEnvPrefixes are the prefixes of reserved variables.
+var reservedEnvPrefixes = []string{"BASH_FUNC_", "DYLD_", "LC_", "LD_"}
+
+func reservedEnvName(name string) bool {
+	for _, p := range reservedEnvPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return reservedEnvNames[name]
+}
+
+// envValues returns the values of the env, dotenv and shell formats named
+// after the variables they are assigned to. Values which would replace a
+// reserved variable are skipped with a warning.
+func envValues(prefix string, values []namedValue) []namedValue {
+	var vars []namedValue
+	for _, v := range values {
+		name := prefix + strings.ToUpper(v.name)
+		if !validEnvName(name) {
+			abortf("%q is not a valid variable name", name)
+		}
+		if reservedEnvName(name) {
+			fmt.Fprintf(os.Stderr, "1pwd: warning: skipping %s, use --env-prefix to export it\n", name)
+			continue
+		}
+		vars = append(vars, namedValue{name, v.value})
+	}
+	return vars
+}
+
+// printValues prints the values in one of the json, env, dotenv, shell,
+// yaml or template=TEMPLATE formats. Variable names of the env, dotenv and
+// shell formats start with envPrefix.
+func printValues(item *opvault.Item, values []namedValue, format, envPrefix string) {
+	switch {
+	case format == "json":
+		// An object with the uuid and the values in their order.
+		var buf bytes.Buffer
+		buf.WriteString("{\n")
+		for i, v := range append([]namedValue{{"uuid", item.UUID}}, values...) {
+			if i > 0 && v.name == "uuid" {
+				continue
+			}
+			name, err := json.Marshal(v.name)
+			assert(err)
+			value, err := json.Marshal(v.value)
+			assert(err)
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			fmt.Fprintf(&buf, "  %s: %s", name, value)
+		}
+		buf.WriteString("\n}\n")
+		_, err := os.Stdout.Write(buf.Bytes())
+		assert(err)
+
+	case format == "env":
+		// The env format has no way to quote values, so values spanning
+		// several lines would add variables.
+		for _, v := range values {
+			if strings.ContainsAny(v.value, "\r\n\x00") {
+				abortf("%s contains a line break, use the dotenv or shell format", v.name)
+			}
+		}
+		for _, v := range envValues(envPrefix, values) {
+			fmt.Printf("%s=%s\n", v.name, v.value)
+		}
+
+	case format == "dotenv":
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+		for _, v := range envValues(envPrefix, values) {
+			fmt.Printf("%s=\"%s\"\n", v.name, r.Replace(v.value))
+		}
+
+	case format == "shell":
+		for _, v := range envValues(envPrefix, values) {
+			fmt.Printf("export %s='%s'\n", v.name, strings.Replace(v.value, "'", `'\''`, -1))
+		}
+
+	case format == "yaml":
+		for _, v := range values {
+			fmt.Printf("%s: %s\n", v.name, strconv.Quote(v.value))
+		}
+
+	case strings.HasPrefix(format, "template="):
+		tmpl, err := template.New("format").Parse(strings.TrimPrefix(format, "template="))
+		if err != nil {
+			abortf("invalid template: %s", err)
+		}
+
+		data := map[string]string{"uuid": item.UUID}
+		for _, v := range values {
+			data[v.name] = v.value
+		}
+
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, data)
+		assert(err)
+		if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		_, err = os.Stdout.Write(buf.Bytes())
+		assert(err)
+
+	default:
+		abortf("unknown format %q", format)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+	"github.com/mattdenner/1pwd/pkg/passgen"
+)
+
+type generateOptions struct {
+	length        int
+	lower         bool
+	upper         bool
+	digits        bool
+	symbols       bool
+	symbolChars   string
+	ambiguous     bool
+	pronounceable bool
+	words         int
+	separator     string
+	capitalize    bool
+
+	save     string
+	url      string
+	username string
+}
+
+// doGenerate prints a new password and its entropy. With opts.save set the
+// password is stored in a new login item as well; the vault is only opened
+// in that case.
+func doGenerate(openVault func() *opvault.Vault, opts *generateOptions) {
+	if opts.words < 0 {
+		abortf("--words must not be negative")
+	}
+	if opts.words == 0 && opts.length <= 0 {
+		abortf("--length must be positive")
+	}
+
+	var avoid string
+	if !opts.ambiguous {
+		avoid = passgen.Ambiguous
+	}
+
+	var (
+		pwd *passgen.Password
+		err error
+	)
+
+	switch {
+	case opts.words > 0:
+		pwd, err = passgen.Passphrase(opts.words, opts.separator, opts.capitalize)
+	case opts.pronounceable:
+		pwd, err = passgen.Pronounceable(opts.length, avoid)
+	default:
+		// Every class that is used appears at least once.
+		var classes []passgen.Class
+		for _, c := range []struct {
+			use   bool
+			chars string
+		}{
+			{opts.lower, passgen.Lower},
+			{opts.upper, passgen.Upper},
+			{opts.digits, passgen.Digits},
+			{opts.symbols, opts.symbolChars},
+		} {
+			if c.use {
+				classes = append(classes, passgen.Class{Chars: c.chars, Min: 1})
+			}
+		}
+
+		pwd, err = passgen.Generate(&passgen.Policy{
+			Length:  opts.length,
+			Classes: classes,
+			Avoid:   avoid,
+		})
+	}
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	fmt.Println(pwd.Value)
+	fmt.Fprintf(os.Stderr, "%.0f bits of entropy\n", pwd.Entropy)
+
+	if opts.save != "" {
+		item := addItem(openVault(), opvault.LoginItem, opts.save, opts.url, opts.username, pwd.Value)
+		fmt.Fprintf(os.Stderr, "saved as %s\n", item.UUID)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// gitCredentialTag marks the items created by the git credential helper.
+// Only those are updated by store and erased when git reports a failed
+// authentication; other logins are only read.
+const gitCredentialTag = "git-credential"
+
+// doGitCredential implements the git credential helper protocol. Git
+// passes attributes (protocol, host, path, username, ...) as key=value
+// lines on stdin; for `get` the matching username and password are written
+// to stdout in the same format.
+//
+// As stdin is used by git, the vault must already be unlocked in the agent.
+func doGitCredential(open func() *opvault.Vault, op string) {
+	attrs, err := readCredentialAttrs(os.Stdin)
+	assert(err)
+
+	u := &url.URL{
+		Scheme: attrs["protocol"],
+		Host:   attrs["host"],
+		Path:   attrs["path"],
+	}
+	if attrs["url"] != "" {
+		u, err = url.Parse(attrs["url"])
+		assert(err)
+	}
+	if u.Host == "" {
+		return
+	}
+
+	vault := open()
+
+	// Items stored by the helper come first, so a password git saved
+	// after another login failed is used from then on.
+	var matches, other []*opvault.Item
+	for _, item := range vault.FindByURL(u) {
+		assert(item.Decrypt(vault))
+		if username := attrs["username"]; username != "" {
+			if v, _ := item.Extract("username"); v != username {
+				continue
+			}
+		}
+		if item.HasTag(gitCredentialTag) {
+			matches = append(matches, item)
+		} else {
+			other = append(other, item)
+		}
+	}
+	tagged := len(matches)
+	matches = append(matches, other...)
+
+	switch op {
+
+	case "get":
+		if len(matches) == 0 {
+			return
+		}
+		item := matches[0]
+		if v, f := item.Extract("username"); f && attrs["username"] == "" {
+			fmt.Printf("username=%s\n", v)
+		}
+		if v, f := item.Extract("password"); f {
+			fmt.Printf("password=%s\n", v)
+		}
+
+	case "store":
+		if attrs["username"] == "" || attrs["password"] == "" {
+			return
+		}
+		if tagged > 0 {
+			item := matches[0]
+			if v, _ := item.Extract("password"); v == attrs["password"] {
+				return
+			}
+			item.Set("password", attrs["password"])
+			assert(vault.Update(item))
+		} else {
+			item, err := opvault.NewItem(opvault.LoginItem)
+			assert(err)
+			item.Set("title", u.Host)
+			item.Set("url", (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String())
+			item.Set("username", attrs["username"])
+			item.Set("password", attrs["password"])
+			item.Data.Tags = append(item.Data.Tags, gitCredentialTag)
+			assert(vault.Add(item))
+		}
+		assert(vault.Save())
+
+	case "erase":
+		for _, item := range matches[:tagged] {
+			if v, _ := item.Extract("password"); attrs["password"] != "" && v != attrs["password"] {
+				continue
+			}
+			assert(vault.Trash(item))
+		}
+		assert(vault.Save())
+
+	}
+}
+
+func readCredentialAttrs(r io.Reader) (map[string]string, error) {
+	var (
+		attrs = map[string]string{}
+		scan  = bufio.NewScanner(r)
+	)
+
+	for scan.Scan() {
+		line := scan.Text()
+		if line == "" {
+			break
+		}
+
+		idx := strings.IndexByte(line, '=')
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid credential attribute %q", line)
+		}
+		attrs[line[:idx]] = line[idx+1:]
+	}
+
+	return attrs, scan.Err()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/alecthomas/template"
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// doInject renders a template in which secrets can be looked up with:
+//
+//	{{ secret "ITEM" "FIELD" }}
+//	{{ totp "ITEM" }}
+//	{{ field "opvault://ITEM/FIELD" }}
+//
+// Unresolved references render as empty strings (with a warning) unless
+// failUnresolved is set.
+func doInject(open func() *opvault.Vault, input, output string, failUnresolved bool) {
+	var (
+		vault *opvault.Vault
+		data  []byte
+		err   error
+	)
+
+	if input == "" || input == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(input)
+	}
+	assert(err)
+
+	resolve := func(ref opvault.Reference) (string, error) {
+		if vault == nil {
+			vault = open()
+		}
+
+		value, err := vault.Resolve(ref)
+		if err != nil && !failUnresolved {
+			fmt.Fprintf(os.Stderr, "1pwd: warning: %s\n", err)
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return fieldValue(ref.Field, value)
+	}
+
+	tmpl, err := template.New("inject").Funcs(template.FuncMap{
+		"secret": func(item, field string) (string, error) {
+			return resolve(opvault.Reference{Item: item, Field: field})
+		},
+		"totp": func(item string) (string, error) {
+			return resolve(opvault.Reference{Item: item, Field: "One-Time Password"})
+		},
+		"field": func(s string) (string, error) {
+			ref, err := opvault.ParseReference(s)
+			if err != nil {
+				return "", err
+			}
+			return resolve(ref)
+		},
+	}).Parse(string(data))
+	assert(err)
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	assert(err)
+
+	if output == "" || output == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = ioutil.WriteFile(output, buf.Bytes(), 0600)
+	}
+	assert(err)
+}
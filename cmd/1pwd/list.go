@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+type listEntry struct {
+	UUID     string   `json:"uuid"`
+	Type     string   `json:"type"`
+	Title    string   `json:"title"`
+	URL      string   `json:"url,omitempty"`
+	Domain   string   `json:"domain,omitempty"`
+	Folder   string   `json:"folder,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Fave     bool     `json:"fave,omitempty"`
+	Trashed  bool     `json:"trashed,omitempty"`
+	Created  string   `json:"created"`
+	Modified string   `json:"modified"`
+}
+
+func doList(vault *opvault.Vault, query, format string) {
+	q, err := opvault.ParseQuery(query)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	items, err := vault.Search(q)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	printItems(vault, items, format)
+}
+
+// searchItems returns the items that also match the query.
+func searchItems(vault *opvault.Vault, items []*opvault.Item, query string) []*opvault.Item {
+	q, err := opvault.ParseQuery(query)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	found, err := vault.Search(q)
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	matches := make(map[*opvault.Item]bool, len(found))
+	for _, item := range found {
+		matches[item] = true
+	}
+
+	var results []*opvault.Item
+	for _, item := range items {
+		if matches[item] {
+			results = append(results, item)
+		}
+	}
+
+	return results
+}
+
+func printItems(vault *opvault.Vault, items []*opvault.Item, format string) {
+	switch format {
+	case "uuid":
+		for _, item := range items {
+			fmt.Println(item.UUID)
+		}
+
+	case "json":
+		entries := make([]*listEntry, 0, len(items))
+		for _, item := range items {
+			entry := &listEntry{
+				UUID:     item.UUID,
+				Type:     item.Category.TypeString(),
+				Title:    item.Data.Title,
+				URL:      item.Data.URL,
+				Domain:   item.Data.Domain,
+				Tags:     item.Data.Tags,
+				Fave:     item.Fave > 0,
+				Trashed:  item.Trashed,
+				Created:  time.Unix(item.Created, 0).UTC().Format(time.RFC3339),
+				Modified: time.Unix(item.Updated, 0).UTC().Format(time.RFC3339),
+			}
+			if folder := vault.FolderOf(item); folder != nil {
+				entry.Folder = folder.Path()
+			}
+			entries = append(entries, entry)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		assert(enc.Encode(entries))
+
+	default:
+		tabw := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+		for _, item := range items {
+			folder := ""
+			if f := vault.FolderOf(item); f != nil {
+				folder = f.Path()
+			}
+			fmt.Fprintf(tabw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				item.UUID,
+				item.Category.TypeString(),
+				trunc(item.Data.Domain, 32),
+				item.Data.Title,
+				folder,
+				strings.Join(item.Data.Tags, ","),
+			)
+		}
+		tabw.Flush()
+	}
+}
@@ -1,182 +1,584 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
-	"os/exec"
-	"regexp"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
 	"github.com/bgentry/speakeasy"
+	"github.com/mattdenner/1pwd/pkg/agent"
+	"github.com/mattdenner/1pwd/pkg/clipboard"
 	"github.com/mattdenner/1pwd/pkg/opvault"
-	"github.com/pquerna/otp/totp"
+	"github.com/mattdenner/1pwd/pkg/passgen"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
-type Finder func(query string, bufIn, bufOut *bytes.Buffer) error
-
-func FinderFor(name string) (Finder, error) {
-	switch name {
-	case "fzy":
-		return FindByFzy, nil
-	case "fzf":
-		return FindByFzf, nil
-	default:
-		return nil, errors.New(fmt.Sprintf("Unknown finder '%s'", name))
-	}
+var itemTypes = []string{
+	opvault.LoginItem.TypeString(),
+	opvault.CreditCardItem.TypeString(),
+	opvault.SecureNoteItem.TypeString(),
+	opvault.IdentityItem.TypeString(),
+	opvault.PasswordItem.TypeString(),
+	opvault.TombstoneItem.TypeString(),
+	opvault.SoftwareLicenseItem.TypeString(),
+	opvault.BankAccountItem.TypeString(),
+	opvault.DatabaseItem.TypeString(),
+	opvault.DriverLicenseItem.TypeString(),
+	opvault.OutdoorLicenseItem.TypeString(),
+	opvault.MembershipItem.TypeString(),
+	opvault.PassportItem.TypeString(),
+	opvault.RewardsItem.TypeString(),
+	opvault.SSNItem.TypeString(),
+	opvault.RouterItem.TypeString(),
+	opvault.ServerItem.TypeString(),
+	opvault.EmailItem.TypeString(),
 }
 
 func main() {
 	var (
 		id         string
 		vaultPath  string
-		extract    string
 		query      string
 		typeFilter string
 		jsonFormat bool
 		finderName string
+		finderConf string
+		title      string
+		itemURL    string
+		username   string
+		password   string
+		askPass    bool
+		setFields  = map[string]string{}
+		iterations int
+		hint       string
+		folderName string
+		name       string
+		output     string
+		openOpts   opvault.OpenOptions
+		socket     string
+		timeout    time.Duration
+		envFiles   []string
+		command    []string
+		input      string
+		strictRefs bool
+		op         string
+		sshSocket  string
+		confirm    bool
+		interact   bool
+		format     string
+		queryTerms []string
+		getOpts    getOptions
+		useFinder  bool
+		nextCode   bool
+		watch      bool
+		waitFresh  int
+		genOpts    generateOptions
+		auditOpts  auditOptions
 	)
 
 	app := kingpin.New("1pwd", "A command-line tool for 1Password.").
 		Author("Simon Menke").
 		Version("1.0.0")
 	app.Flag("vault", "Vault to read").Short('V').StringVar(&vaultPath)
+	app.Flag("profile", "Profile of the vault to read").Short('P').Default("default").StringVar(&openOpts.Profile)
+	app.Flag("strict", "Refuse to open a vault with invalid item HMACs").BoolVar(&openOpts.Strict)
+	app.Flag("agent-socket", "Socket of the unlock agent").Default(agent.SocketPath()).OverrideDefaultFromEnvar("ONEPWD_AGENT_SOCK").StringVar(&socket)
 
 	get := app.Command("get", "Get an entry")
 	get.Arg("id", "ID of item.").Required().StringVar(&id)
-	get.Arg("extract", "Field to extract").StringVar(&extract)
+	get.Arg("extract", "Field to extract").StringVar(&getOpts.extract)
 	get.Flag("json", "Print JSON formatted data").Short('j').BoolVar(&jsonFormat)
+	get.Flag("format", "Output format (table, json, yaml, env, dotenv, shell or template=TEMPLATE)").Default("table").StringVar(&getOpts.format)
+	get.Flag("env-prefix", "Prefix of the variable names of the env, dotenv and shell formats").StringVar(&getOpts.envPrefix)
+	get.Flag("reveal", "Show concealed values in the table format").BoolVar(&getOpts.reveal)
+	get.Flag("copy", "Copy the field (the password by default) to the clipboard instead of printing it").Short('c').BoolVar(&getOpts.copy)
+	get.Flag("clipboard", "Clipboard to copy to").Default("auto").OverrideDefaultFromEnvar("ONEPWD_CLIPBOARD").EnumVar(&getOpts.clipboard, clipboard.Names...)
+	get.Flag("clear-after", "Clear the clipboard after this time (0 to keep it)").Default("45s").DurationVar(&getOpts.clearAfter)
 
 	search := app.Command("search", "Search for an entry")
-	search.Arg("extract", "Field to extract").StringVar(&extract)
-	search.Flag("type", "Entry type").Short('t').Default("login").EnumVar(&typeFilter,
-		"any",
-		opvault.LoginItem.TypeString(),
-		opvault.CreditCardItem.TypeString(),
-		opvault.SecureNoteItem.TypeString(),
-		opvault.IdentityItem.TypeString(),
-		opvault.PasswordItem.TypeString(),
-		opvault.TombstoneItem.TypeString(),
-		opvault.SoftwareLicenseItem.TypeString(),
-		opvault.BankAccountItem.TypeString(),
-		opvault.DatabaseItem.TypeString(),
-		opvault.DriverLicenseItem.TypeString(),
-		opvault.OutdoorLicenseItem.TypeString(),
-		opvault.MembershipItem.TypeString(),
-		opvault.PassportItem.TypeString(),
-		opvault.RewardsItem.TypeString(),
-		opvault.SSNItem.TypeString(),
-		opvault.RouterItem.TypeString(),
-		opvault.ServerItem.TypeString(),
-		opvault.EmailItem.TypeString())
+	search.Arg("extract", "Field to extract").StringVar(&getOpts.extract)
+	search.Flag("type", "Entry type").Short('t').Default("login").EnumVar(&typeFilter, append([]string{"any"}, itemTypes...)...)
 	search.Flag("query", "Initial query").Short('q').StringVar(&query)
 	search.Flag("json", "Print JSON formatted data").Short('j').BoolVar(&jsonFormat)
-	search.Flag("finder", "The fuzzy finder to use").Short('f').Default("fzy").EnumVar(&finderName, "fzy", "fzf")
+	search.Flag("format", "Output format (table, json, yaml, env, dotenv, shell or template=TEMPLATE; table, json or uuid with --no-interactive)").Default("table").StringVar(&getOpts.format)
+	search.Flag("env-prefix", "Prefix of the variable names of the env, dotenv and shell formats").StringVar(&getOpts.envPrefix)
+	search.Flag("reveal", "Show concealed values in the table format").BoolVar(&getOpts.reveal)
+	search.Flag("copy", "Copy the field (the password by default) to the clipboard instead of printing it").Short('c').BoolVar(&getOpts.copy)
+	search.Flag("clipboard", "Clipboard to copy to").Default("auto").OverrideDefaultFromEnvar("ONEPWD_CLIPBOARD").EnumVar(&getOpts.clipboard, clipboard.Names...)
+	search.Flag("clear-after", "Clear the clipboard after this time (0 to keep it)").Default("45s").DurationVar(&getOpts.clearAfter)
+	search.Flag("finder", "The fuzzy finder to use (builtin, fzy, fzf or one from the finder config)").Short('f').OverrideDefaultFromEnvar("ONEPWD_FINDER").StringVar(&finderName)
+	search.Flag("finder-config", "Configuration file of external finders").Default(DefaultFinderConfig()).OverrideDefaultFromEnvar("ONEPWD_FINDER_CONFIG").StringVar(&finderConf)
+	search.Flag("folder", "Only search the folder (and its sub folders) or smart folder").StringVar(&folderName)
+	search.Flag("interactive", "Start a finder (--no-interactive prints all the entries matching the query)").Default("true").BoolVar(&interact)
+
+	list := app.Command("list", "List the entries matching a query")
+	list.Arg("query", "Query (title:, url:, domain:, type:, folder:, tag:, uuid:, trashed, fave)").StringsVar(&queryTerms)
+	list.Flag("format", "Output format").Default("table").EnumVar(&format, "table", "json", "uuid")
+
+	add := app.Command("add", "Add a new entry")
+	add.Arg("title", "Title of the item.").Required().StringVar(&title)
+	add.Flag("type", "Entry type").Short('t').Default("login").EnumVar(&typeFilter, itemTypes...)
+	add.Flag("url", "URL of the item").StringVar(&itemURL)
+	add.Flag("username", "Username of the item").StringVar(&username)
+	add.Flag("password", "Password of the item").StringVar(&password)
+
+	edit := app.Command("edit", "Edit an entry")
+	edit.Arg("id", "ID of item.").Required().StringVar(&id)
+	edit.Flag("title", "New title of the item").StringVar(&title)
+	edit.Flag("url", "New URL of the item").StringVar(&itemURL)
+	edit.Flag("username", "New username of the item").StringVar(&username)
+	edit.Flag("password", "New password of the item").StringVar(&password)
+	edit.Flag("ask-password", "Prompt for the new password").BoolVar(&askPass)
+	edit.Flag("set", "Set a field (FIELD=VALUE)").StringMapVar(&setFields)
+
+	trash := app.Command("trash", "Move an entry to the trash")
+	trash.Arg("id", "ID of item.").Required().StringVar(&id)
+
+	restore := app.Command("restore", "Restore an entry from the trash")
+	restore.Arg("id", "ID of item.").Required().StringVar(&id)
+
+	purge := app.Command("purge", "Permanently delete an entry")
+	purge.Arg("id", "ID of item.").Required().StringVar(&id)
+
+	initCmd := app.Command("init", "Create a new vault")
+	initCmd.Arg("path", "Path of the new vault.").Required().StringVar(&vaultPath)
+	initCmd.Flag("iterations", "Number of PBKDF2 iterations").Default(strconv.Itoa(opvault.DefaultIterations)).IntVar(&iterations)
+	initCmd.Flag("hint", "Password hint").StringVar(&hint)
+
+	passwd := app.Command("passwd", "Change the master password")
+	passwd.Flag("iterations", "Number of PBKDF2 iterations (default: keep current)").IntVar(&iterations)
+
+	folders := app.Command("folders", "List the folders")
+
+	attachment := app.Command("attachment", "Read the attachments of an entry")
+	attachmentLs := attachment.Command("ls", "List the attachments of an entry")
+	attachmentLs.Arg("id", "ID of item.").Required().StringVar(&id)
+	attachmentGet := attachment.Command("get", "Extract an attachment of an entry")
+	attachmentGet.Arg("id", "ID of item.").Required().StringVar(&id)
+	attachmentGet.Arg("name", "Filename or ID of the attachment").StringVar(&name)
+	attachmentGet.Flag("output", "Write the attachment to a file").Short('o').StringVar(&output)
+
+	profiles := app.Command("profiles", "List the profiles of the vault")
+
+	verify := app.Command("verify", "Verify the integrity of the vault")
+
+	agentCmd := app.Command("agent", "Run an agent which keeps vaults unlocked")
+	agentCmd.Flag("timeout", "Forget the keys after being idle for this long").Default("15m").DurationVar(&timeout)
+
+	lock := app.Command("lock", "Make the agent forget all keys")
+
+	run := app.Command("run", "Run a command with secret references in its environment resolved")
+	run.Flag("env-file", "Read environment variables from a file").Default(".env").StringsVar(&envFiles)
+	run.Arg("command", "Command to run (after --)").Required().StringsVar(&command)
+
+	inject := app.Command("inject", "Render a template with secrets from the vault")
+	inject.Flag("in", "Template to render (default: stdin)").Short('i').StringVar(&input)
+	inject.Flag("out", "File to write (default: stdout)").Short('o').StringVar(&output)
+	inject.Flag("fail-unresolved", "Fail when a reference cannot be resolved").BoolVar(&strictRefs)
+
+	unlock := app.Command("unlock", "Unlock the vault in the agent")
+
+	gitCredential := app.Command("git-credential", "Git credential helper")
+	gitCredential.Arg("operation", "Operation requested by git").Required().EnumVar(&op, "get", "store", "erase")
+
+	sshAgent := app.Command("ssh-agent", "Run an ssh-agent serving the SSH keys in the vault")
+	sshAgent.Flag("socket", "Socket to listen on").Default(filepath.Join(filepath.Dir(agent.SocketPath()), "ssh-agent.sock")).StringVar(&sshSocket)
+	sshAgent.Flag("confirm", "Ask for confirmation before every signature").BoolVar(&confirm)
+
+	clipboardClear := app.Command("clipboard-clear", "Clear the clipboard if it still holds the contents with the SHA-256 sum on stdin").Hidden()
+	clipboardClear.Flag("clipboard", "Clipboard to clear").Required().EnumVar(&getOpts.clipboard, clipboard.Names...)
+	clipboardClear.Flag("after", "Time to wait before clearing").Required().DurationVar(&getOpts.clearAfter)
+
+	totp := app.Command("totp", "Print the one-time password of an entry")
+	totp.Arg("id", "ID or title of item.").StringVar(&id)
+	totp.Flag("search", "Pick the entry with the fuzzy finder").BoolVar(&useFinder)
+	totp.Flag("query", "Initial query").Short('q').StringVar(&query)
+	totp.Flag("finder", "The fuzzy finder to use (builtin, fzy, fzf or one from the finder config)").Short('f').OverrideDefaultFromEnvar("ONEPWD_FINDER").StringVar(&finderName)
+	totp.Flag("finder-config", "Configuration file of external finders").Default(DefaultFinderConfig()).OverrideDefaultFromEnvar("ONEPWD_FINDER_CONFIG").StringVar(&finderConf)
+	totp.Flag("next", "Also print the next code").BoolVar(&nextCode)
+	totp.Flag("watch", "Keep refreshing the code until interrupted").Short('w').BoolVar(&watch)
+	totp.Flag("wait-fresh", "Wait for the next code when the current one expires within N seconds").PlaceHolder("N").IntVar(&waitFresh)
+
+	generate := app.Command("generate", "Generate a password")
+	generate.Flag("length", "Number of characters").Short('l').Default("20").IntVar(&genOpts.length)
+	generate.Flag("lower", "Use lower case letters").Default("true").BoolVar(&genOpts.lower)
+	generate.Flag("upper", "Use upper case letters").Default("true").BoolVar(&genOpts.upper)
+	generate.Flag("digits", "Use digits").Default("true").BoolVar(&genOpts.digits)
+	generate.Flag("symbols", "Use symbols").Default("true").BoolVar(&genOpts.symbols)
+	generate.Flag("symbol-chars", "The symbols to use").Default(passgen.Symbols).StringVar(&genOpts.symbolChars)
+	generate.Flag("ambiguous", "Use characters which are easily confused ("+passgen.Ambiguous+")").Default("true").BoolVar(&genOpts.ambiguous)
+	generate.Flag("pronounceable", "Alternate consonants and vowels").BoolVar(&genOpts.pronounceable)
+	generate.Flag("words", "Generate a passphrase of N words instead").PlaceHolder("N").IntVar(&genOpts.words)
+	generate.Flag("separator", "Separator of the passphrase words").Default("-").StringVar(&genOpts.separator)
+	generate.Flag("capitalize", "Capitalize the passphrase words").BoolVar(&genOpts.capitalize)
+	generate.Flag("save", "Save the password in a new login entry").PlaceHolder("TITLE").StringVar(&genOpts.save)
+	generate.Flag("url", "URL of the saved entry").StringVar(&genOpts.url)
+	generate.Flag("username", "Username of the saved entry").StringVar(&genOpts.username)
+
+	audit := app.Command("audit", "Report reused, weak and old passwords, missing one-time passwords and HTTP URLs")
+	audit.Arg("query", "Only audit the entries matching the query").StringsVar(&queryTerms)
+	audit.Flag("format", "Output format").Default("table").EnumVar(&auditOpts.format, "table", "json")
+	audit.Flag("min-length", "Report shorter passwords").Default("12").IntVar(&auditOpts.minLength)
+	audit.Flag("min-entropy", "Report passwords with fewer bits of entropy").Default("60").Float64Var(&auditOpts.minEntropy)
+	audit.Flag("max-age", "Report passwords unchanged for more than N days (0 to disable)").Default("365").PlaceHolder("N").IntVar(&auditOpts.maxAge)
+	audit.Flag("otp-domain", "Also expect one-time passwords for logins of this domain").StringsVar(&auditOpts.otpDomains)
+
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	if jsonFormat {
+		getOpts.format = "json"
+	}
+	// search --no-interactive lists the entries like list does
+	listing := cmd == search.FullCommand() && !interact
+	switch {
+	case listing && getOpts.format != "table" && getOpts.format != "json" && getOpts.format != "uuid":
+		abortf("format %q cannot list entries, use table, json or uuid", getOpts.format)
+	case !listing && getOpts.format != "" && !validFormat(getOpts.format):
+		abortf("unknown format %q", getOpts.format)
+	}
 
-	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
+	switch cmd {
 
 	case get.FullCommand():
-		doGet(openVault(vaultPath), id, extract, jsonFormat)
+		doGet(openVault(vaultPath, socket, &openOpts), id, &getOpts)
 	case search.FullCommand():
-		if finder, err := FinderFor(finderName); err != nil {
-			panic(err)
+		if finder, err := FinderFor(finderName, finderConf); err != nil {
+			abortf("%s", err)
 		} else {
-			doSearch(openVault(vaultPath), finder, query, typeFilter, extract, jsonFormat)
+			doSearch(openVault(vaultPath, socket, &openOpts), finder, query, typeFilter, folderName, !interact, &getOpts)
+		}
+	case list.FullCommand():
+		doList(openVault(vaultPath, socket, &openOpts), strings.Join(queryTerms, " "), format)
+	case add.FullCommand():
+		doAdd(openVault(vaultPath, socket, &openOpts), typeFilter, title, itemURL, username, password)
+	case edit.FullCommand():
+		if title != "" {
+			setFields["title"] = title
+		}
+		if itemURL != "" {
+			setFields["url"] = itemURL
+		}
+		if username != "" {
+			setFields["username"] = username
+		}
+		if password != "" {
+			setFields["password"] = password
+		}
+		vault := openVault(vaultPath, socket, &openOpts)
+		if askPass {
+			pwd, err := speakeasy.FAsk(os.Stderr, "New Password: ")
+			assert(err)
+			setFields["password"] = pwd
+		}
+		doEdit(vault, id, setFields)
+	case trash.FullCommand():
+		vault := openVault(vaultPath, socket, &openOpts)
+		doChange(vault, id, vault.Trash)
+	case restore.FullCommand():
+		vault := openVault(vaultPath, socket, &openOpts)
+		doChange(vault, id, vault.Restore)
+	case purge.FullCommand():
+		vault := openVault(vaultPath, socket, &openOpts)
+		doChange(vault, id, vault.Purge)
+	case initCmd.FullCommand():
+		doInit(vaultPath, iterations, hint)
+	case passwd.FullCommand():
+		doPasswd(vaultPath, &openOpts, iterations)
+	case folders.FullCommand():
+		doFolders(openVault(vaultPath, socket, &openOpts))
+	case attachmentLs.FullCommand():
+		doAttachmentLs(openVault(vaultPath, socket, &openOpts), id)
+	case attachmentGet.FullCommand():
+		doAttachmentGet(openVault(vaultPath, socket, &openOpts), id, name, output)
+	case profiles.FullCommand():
+		doProfiles(vaultPath)
+	case verify.FullCommand():
+		doVerify(vaultPath, openOpts.Profile)
+	case agentCmd.FullCommand():
+		doAgent(socket, timeout)
+	case lock.FullCommand():
+		assert(agent.Lock(socket))
+	case run.FullCommand():
+		doRun(func() *opvault.Vault { return openVault(vaultPath, socket, &openOpts) }, envFiles, command)
+	case unlock.FullCommand():
+		openVault(vaultPath, socket, &openOpts)
+	case gitCredential.FullCommand():
+		doGitCredential(func() *opvault.Vault { return openAgentVault(vaultPath, socket, &openOpts) }, op)
+	case sshAgent.FullCommand():
+		doSSHAgent(openVault(vaultPath, socket, &openOpts), sshSocket, confirm)
+	case clipboardClear.FullCommand():
+		doClipboardClear(getOpts.clipboard, getOpts.clearAfter)
+	case totp.FullCommand():
+		var finder Finder
+		if !useFinder && id == "" {
+			abortf("specify an entry or use --search")
 		}
+		if useFinder {
+			var err error
+			finder, err = FinderFor(finderName, finderConf)
+			if err != nil {
+				abortf("%s", err)
+			}
+		}
+		doTOTP(openVault(vaultPath, socket, &openOpts), finder, id, query, nextCode, watch, waitFresh)
+	case generate.FullCommand():
+		doGenerate(func() *opvault.Vault { return openVault(vaultPath, socket, &openOpts) }, &genOpts)
+	case audit.FullCommand():
+		doAudit(openVault(vaultPath, socket, &openOpts), strings.Join(queryTerms, " "), &auditOpts)
+	case inject.FullCommand():
+		doInject(func() *opvault.Vault { return openVault(vaultPath, socket, &openOpts) }, input, output, strictRefs)
 	}
 }
 
-func openVault(vaultPath string) *opvault.Vault {
-	if vaultPath == "" {
-		vaults, err := opvault.LookupVaults()
-		assert(err)
+func openVault(vaultPath, socket string, opts *opvault.OpenOptions) *opvault.Vault {
+	vaultPath = lookupVault(vaultPath)
 
-		if len(vaults) == 0 {
-			abortf("no vaults found")
-		}
-
-		vaultPath = vaults[0]
+	if vault := openWithAgent(vaultPath, socket, opts); vault != nil {
+		return vault
 	}
 
 	pwd, err := speakeasy.FAsk(os.Stderr, "Master Password: ")
 	assert(err)
 
-	vault, err := opvault.Open(vaultPath, pwd)
+	vault, err := opvault.OpenWithOptions(vaultPath, pwd, opts)
 	assert(err)
+	warnInvalidHMACs(vault)
+
+	// hand the keys to the agent (when it is running)
+	agent.Put(socket, vaultPath, opts.Profile, vault.Keys())
 
 	return vault
 }
 
-func FindByFzy(query string, bufIn, bufOut *bytes.Buffer) error {
-	cmd := exec.Command("fzy", "--query="+query)
-	cmd.Env = os.Environ()
-	cmd.Stdin = bufIn
-	cmd.Stdout = bufOut
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// openAgentVault opens the vault with the keys held by the agent, for
+// commands which cannot prompt for the master password.
+func openAgentVault(vaultPath, socket string, opts *opvault.OpenOptions) *opvault.Vault {
+	vaultPath = lookupVault(vaultPath)
+
+	vault := openWithAgent(vaultPath, socket, opts)
+	if vault == nil {
+		abortf("vault is locked (run `1pwd agent` and `1pwd unlock`)")
+	}
+
+	return vault
 }
 
-func FindByFzf(query string, bufIn, bufOut *bytes.Buffer) error {
-	cmd := exec.Command("fzf", "--ansi", "--with-nth=2..", "--nth=4..,3,1", "--query="+query)
-	cmd.Env = os.Environ()
-	cmd.Stdin = bufIn
-	cmd.Stdout = bufOut
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func openWithAgent(vaultPath, socket string, opts *opvault.OpenOptions) *opvault.Vault {
+	keys, err := agent.Get(socket, vaultPath, opts.Profile)
+	if err != nil {
+		return nil
+	}
+
+	withKeys := *opts
+	withKeys.Keys = keys
+
+	vault, err := opvault.OpenWithOptions(vaultPath, "", &withKeys)
+	if err != nil {
+		return nil
+	}
+	warnInvalidHMACs(vault)
+
+	return vault
 }
 
-func doSearch(vault *opvault.Vault, finder Finder, query, typeFilter, extract string, jsonFormat bool) {
+// warnInvalidHMACs warns about the items of a vault (opened without
+// --strict) whose HMAC does not match.
+func warnInvalidHMACs(vault *opvault.Vault) {
+	for _, item := range vault.All() {
+		if !item.ValidHMAC() {
+			fmt.Fprintf(os.Stderr, "1pwd: warning: item %s: %s (run `1pwd verify`)\n", item.UUID, opvault.ErrInvalidHMAC)
+		}
+	}
+}
+
+func lookupVault(vaultPath string) string {
+	if vaultPath == "" {
+		vaults, err := opvault.LookupVaults()
+		assert(err)
+
+		if len(vaults) == 0 {
+			abortf("no vaults found")
+		}
+
+		vaultPath = vaults[0]
+	}
+
+	return vaultPath
+}
+
+func doSearch(vault *opvault.Vault, finder Finder, query, typeFilter, folderName string, noInteract bool, opts *getOptions) {
 	if typeFilter == "any" {
 		typeFilter = ""
 	}
 	cat := opvault.FromTypeString(typeFilter)
 
-	results := vault.All()
-
-	var bufIn bytes.Buffer
-	var bufOut bytes.Buffer
+	var folder *opvault.Folder
+	if folderName != "" {
+		var err error
+		folder, err = vault.Folder(folderName)
+		if os.IsNotExist(err) {
+			abortf("folder %q not found", folderName)
+		}
+		assert(err)
+	}
 
-	tabw := tabwriter.NewWriter(&bufIn, 8, 8, 2, ' ', tabwriter.StripEscape)
-	for _, result := range results {
+	var items []*opvault.Item
+	for _, result := range vault.All() {
 		if result.Trashed {
 			continue
 		}
+		if typeFilter == "" && result.Category == opvault.TombstoneItem {
+			continue
+		}
 		if typeFilter != "" && result.Category != cat {
 			continue
 		}
+		if folder != nil {
+			in, err := vault.InFolder(result, folder)
+			if err != nil {
+				abortf("cannot search smart folder %q: %s", folderName, err)
+			}
+			if !in {
+				continue
+			}
+		}
+		items = append(items, result)
+	}
+
+	if noInteract {
+		printItems(vault, searchItems(vault, items, query), opts.format)
+		return
+	}
+
+	item, err := finder(vault, query, items)
+	assert(err)
+
+	if item != nil {
+		doGet(vault, item.UUID, opts)
+	}
+}
+
+func doAgent(socket string, timeout time.Duration) {
+	l, err := agent.Listen(socket)
+	assert(err)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "1pwd: agent listening on %s\n", socket)
+
+	err = agent.New(timeout).Serve(l)
+	os.Remove(socket)
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		assert(err)
+	}
+}
+
+func doProfiles(vaultPath string) {
+	names, err := opvault.Profiles(lookupVault(vaultPath))
+	assert(err)
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
+
+func doVerify(vaultPath, profile string) {
+	vaultPath = lookupVault(vaultPath)
+
+	pwd, err := speakeasy.FAsk(os.Stderr, "Master Password: ")
+	assert(err)
+
+	problems, err := opvault.Verify(vaultPath, profile, pwd)
+	assert(err)
 
-		fmt.Fprintf(tabw,
-			field("%s", "")+
-				field("%s", "2")+
-				field("%s", "")+
-				field("%s", "blue")+
-				field("%s", "yellow")+
-				"\n",
-			result.UUID,
-			result.UUID[:8],
-			result.Category.String(),
-			trunc(result.Data.Domain, 32),
-			result.Data.Title,
-		)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		abortf("found %d problem(s)", len(problems))
+	}
+}
+
+func doFolders(vault *opvault.Vault) {
+	tabw := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+	for _, folder := range vault.Folders() {
+		kind := "folder"
+		if folder.Smart {
+			kind = "smart"
+		}
+		fmt.Fprintf(tabw, "%s\t%s\t%s\n", folder.UUID, kind, folder.Path())
 	}
 	tabw.Flush()
+}
 
-	err := finder(query, &bufIn, &bufOut)
+func doAttachmentLs(vault *opvault.Vault, id string) {
+	item, err := vault.Get(id)
 	assert(err)
 
-	var id string
-	fmt.Fscan(&bufOut, &id)
-	if id != "" {
-		doGet(vault, id, extract, jsonFormat)
+	tabw := tabwriter.NewWriter(os.Stdout, 8, 8, 2, ' ', 0)
+	for _, a := range item.Attachments() {
+		fmt.Fprintf(tabw, "%s\t%d\t%s\n", a.UUID, a.ContentsSize, a.Data.Filename)
 	}
+	tabw.Flush()
 }
 
-func doGet(vault *opvault.Vault, id, extract string, jsonFormat bool) {
+func doAttachmentGet(vault *opvault.Vault, id, name, output string) {
+	item, err := vault.Get(id)
+	assert(err)
+
+	var found []*opvault.Attachment
+	for _, a := range item.Attachments() {
+		if name == "" || a.UUID == name || a.Data.Filename == name {
+			found = append(found, a)
+		}
+	}
+	switch {
+	case len(found) == 0 && name == "":
+		abortf("item has no attachments")
+	case len(found) == 0:
+		abortf("attachment %q not found", name)
+	case len(found) > 1:
+		abortf("item has multiple attachments, specify one by name")
+	}
+
+	data, err := found[0].Contents(vault)
+	assert(err)
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(output, data, 0600)
+	}
+	assert(err)
+}
+
+// getOptions control how doGet prints an item.
+type getOptions struct {
+	extract    string
+	format     string
+	envPrefix  string
+	reveal     bool
+	copy       bool
+	clipboard  string
+	clearAfter time.Duration
+}
+
+func doGet(vault *opvault.Vault, id string, opts *getOptions) {
 
 	item, err := vault.Get(id)
 	assert(err)
@@ -184,56 +586,193 @@ func doGet(vault *opvault.Vault, id, extract string, jsonFormat bool) {
 	err = item.Decrypt(vault)
 	assert(err)
 
-	var (
-		v interface{} = item.Data
-		f             = true
-	)
+	if opts.copy {
+		field := opts.extract
+		if field == "" {
+			field = "password"
+		}
 
-	if extract != "" {
-		v, f = item.Extract(extract)
-		if !f {
-			abortf("field %q not found", extract)
+		value, found := item.Extract(field)
+		if !found {
+			abortf("field %q not found", field)
+		}
+
+		value, err = fieldValue(field, value)
+		if err != nil {
+			abortf("%s", err)
 		}
+
+		copyToClipboard(opts.clipboard, field, value, opts.clearAfter)
+		return
 	}
 
-	if jsonFormat {
-		err = json.NewEncoder(os.Stdout).Encode(v)
-		assert(err)
-	} else {
-		if extract != "" {
-			fmt.Printf("%s\n", displayFieldValue(extract, v.(string)))
+	var value string
+	if opts.extract != "" {
+		var found bool
+		value, found = item.Extract(opts.extract)
+		if !found {
+			abortf("field %q not found", opts.extract)
+		}
+		if value, err = fieldValue(opts.extract, value); err != nil {
+			abortf("%s", err)
+		}
+	}
+
+	switch opts.format {
+	case "table":
+		if opts.extract != "" {
+			fmt.Printf("%s\n", value)
 		} else {
-			tabw := tabwriter.NewWriter(os.Stdout, 10, 4, 0, ' ', 0)
-			fmt.Fprintf(tabw, "id:\t%s\n", item.UUID)
-			fields := map[string]string{"url": "url", "username": "username", "password": "password", "one-time": "One-Time Password"}
-			for n, e := range fields {
-				if v, f := item.Extract(e); f {
-					fmt.Fprintf(tabw, "%s:\t%s\n", n, displayFieldValue(e, v))
-				}
-			}
-			tabw.Flush()
+			printTable(item, opts.reveal)
 		}
+	default:
+		values := itemValues(item)
+		if opts.extract != "" {
+			values = []namedValue{{valueName(opts.extract), value}}
+		}
+		printValues(item, values, opts.format, opts.envPrefix)
 	}
 }
 
-func displayFieldValue(f, v string) string {
+func doAdd(vault *opvault.Vault, typeFilter, title, itemURL, username, password string) {
+	item := addItem(vault, opvault.FromTypeString(typeFilter), title, itemURL, username, password)
+	fmt.Println(item.UUID)
+}
+
+// addItem creates an item and saves it in the vault.
+func addItem(vault *opvault.Vault, category opvault.Category, title, itemURL, username, password string) *opvault.Item {
+	item, err := opvault.NewItem(category)
+	assert(err)
+
+	item.Set("title", title)
+	item.Set("url", itemURL)
+	if username != "" {
+		item.Set("username", username)
+	}
+	if password != "" {
+		item.Set("password", password)
+	}
+
+	err = vault.Add(item)
+	assert(err)
+
+	err = vault.Save()
+	assert(err)
+
+	return item
+}
+
+func doEdit(vault *opvault.Vault, id string, fields map[string]string) {
+	if len(fields) == 0 {
+		abortf("nothing to change")
+	}
+
+	item, err := vault.Get(id)
+	assert(err)
+
+	err = item.Decrypt(vault)
+	assert(err)
+
+	for f, v := range fields {
+		if !item.Set(f, v) {
+			abortf("field %q not found", f)
+		}
+	}
+
+	err = vault.Update(item)
+	assert(err)
+
+	err = vault.Save()
+	assert(err)
+}
+
+func doInit(vaultPath string, iterations int, hint string) {
+	pwd := askNewPassword("Master Password: ")
+
+	_, err := opvault.Create(vaultPath, pwd, &opvault.CreateOptions{
+		Iterations:   iterations,
+		PasswordHint: hint,
+	})
+	assert(err)
+}
+
+func doPasswd(vaultPath string, opts *opvault.OpenOptions, iterations int) {
+	vaultPath = lookupVault(vaultPath)
+
+	old, err := speakeasy.FAsk(os.Stderr, "Current Master Password: ")
+	assert(err)
+
+	vault, err := opvault.OpenWithOptions(vaultPath, old, opts)
+	assert(err)
+
+	pwd := askNewPassword("New Master Password: ")
+
+	err = vault.ChangeMasterPassword(old, pwd, iterations)
+	assert(err)
+}
+
+func askNewPassword(prompt string) string {
+	pwd, err := speakeasy.FAsk(os.Stderr, prompt)
+	assert(err)
+
+	if pwd == "" {
+		abortf("password cannot be empty")
+	}
+
+	confirm, err := speakeasy.FAsk(os.Stderr, "Confirm "+prompt)
+	assert(err)
+
+	if pwd != confirm {
+		abortf("passwords do not match")
+	}
+
+	return pwd
+}
+
+func doChange(vault *opvault.Vault, id string, change func(*opvault.Item) error) {
+	item, err := vault.Get(id)
+	assert(err)
+
+	err = change(item)
+	assert(err)
+
+	err = vault.Save()
+	assert(err)
+}
+
+// errCounterOTP is returned for counter based one-time passwords, as a
+// code is used up once shown and only the totp command saves the counter.
+var errCounterOTP = errors.New("counter based one-time passwords are only shown by `1pwd totp`")
+
+// fieldValue returns the value of a field as it is printed, which is the
+// current code for a one-time password.
+func fieldValue(f, v string) (string, error) {
 	switch f {
-	case "One-Time Password":
-		r, _ := regexp.Compile("[?&]secret=([^&]+)")
-		m := r.FindStringSubmatch(v)
-		var secret = strings.ToUpper(m[1]) + strings.Repeat("=", (8-(len(m[1])%8))%8)
-		var passcode, err = totp.GenerateCode(secret, time.Now().UTC())
+	case "One-Time Password", "totp":
+		otp, err := opvault.ParseOTP(v)
 		if err != nil {
-			return "******"
-		} else {
-			return passcode
+			return "", err
+		}
+		if otp.Type == "hotp" {
+			return "", errCounterOTP
 		}
+		return otp.Code(time.Now()).Code, nil
 
 	default:
-		return v
+		return v, nil
 	}
 }
 
+// displayFieldValue is like fieldValue, but masks the values it cannot
+// show.
+func displayFieldValue(f, v string) string {
+	s, err := fieldValue(f, v)
+	if err != nil {
+		return "******"
+	}
+	return s
+}
+
 func trunc(s string, max int) string {
 	if len(s) > max {
 		return s[:max-3] + "..."
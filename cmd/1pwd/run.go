@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// doRun starts command with an environment in which every secret reference
+// (from the current environment and the env files) is replaced by its
+// value. The values are only passed to the child; they never touch disk.
+func doRun(open func() *opvault.Vault, envFiles []string, command []string) {
+	var env []string
+
+	env = append(env, os.Environ()...)
+	for _, path := range envFiles {
+		vars, err := readEnvFile(path)
+		if os.IsNotExist(err) && path == ".env" {
+			continue
+		}
+		assert(err)
+		env = append(env, vars...)
+	}
+
+	var vault *opvault.Vault
+	for i, kv := range env {
+		idx := strings.IndexByte(kv, '=')
+		if idx < 0 || !opvault.IsReference(kv[idx+1:]) {
+			continue
+		}
+
+		ref, err := opvault.ParseReference(kv[idx+1:])
+		assert(err)
+
+		if vault == nil {
+			vault = open()
+		}
+
+		value, err := vault.Resolve(ref)
+		assert(err)
+
+		value, err = fieldValue(ref.Field, value)
+		if err != nil {
+			abortf("%s: %s", kv[:idx], err)
+		}
+
+		env[i] = kv[:idx+1] + value
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	err := cmd.Start()
+	assert(err)
+
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	signal.Stop(signals)
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			os.Exit(status.ExitStatus())
+		}
+		os.Exit(1)
+	}
+	assert(err)
+}
+
+// readEnvFile reads KEY=VALUE lines from a dotenv file. Blank lines and
+// comments are skipped, an `export ` prefix is allowed and values may be
+// quoted.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		vars []string
+		n    int
+		scan = bufio.NewScanner(f)
+	)
+
+	for scan.Scan() {
+		n++
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		idx := strings.IndexByte(line, '=')
+		if idx <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		vars = append(vars, key+"="+value)
+	}
+
+	return vars, scan.Err()
+}
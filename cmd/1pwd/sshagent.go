@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/mattdenner/1pwd/pkg/agent"
+	"github.com/mattdenner/1pwd/pkg/opvault"
+	"github.com/mattdenner/1pwd/pkg/sshagent"
+)
+
+const maxKeyAttachmentSize = 64 * 1024
+
+// doSSHAgent serves the SSH private keys stored in the vault (in fields,
+// notes and attachments) over an ssh-agent socket.
+func doSSHAgent(vault *opvault.Vault, socket string, confirm bool) {
+	l, err := agent.Listen(socket)
+	assert(err)
+
+	a := &sshagent.Agent{
+		Load: func() ([]*sshagent.Key, error) { return findSSHKeys(vault), nil },
+	}
+	if confirm {
+		a.Confirm = confirmSSHKey
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+	}()
+
+	fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", socket)
+
+	err = a.Serve(l, func(conn net.Conn) error {
+		if unix, ok := conn.(*net.UnixConn); ok {
+			return agent.CheckPeer(unix)
+		}
+		return errors.New("not a unix socket")
+	})
+	os.Remove(socket)
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		assert(err)
+	}
+}
+
+func findSSHKeys(vault *opvault.Vault) []*sshagent.Key {
+	var keys []*sshagent.Key
+
+	add := func(item *opvault.Item, value string) {
+		if !strings.Contains(value, "PRIVATE KEY-----") {
+			return
+		}
+
+		found, errs := sshagent.ParseKeys([]byte(value), item.Data.Title)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "1pwd: warning: %s: %s\n", item.Data.Title, err)
+		}
+		keys = append(keys, found...)
+	}
+
+	for _, item := range vault.All() {
+		if item.Trashed || item.Category == opvault.TombstoneItem {
+			continue
+		}
+
+		err := item.Decrypt(vault)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "1pwd: warning: %s: %s\n", item.Data.Title, err)
+			continue
+		}
+
+		add(item, item.Data.Password)
+		add(item, item.Data.NotesPlain)
+		for _, f := range item.Data.Fields {
+			add(item, f.Value)
+		}
+		for _, s := range item.Data.Sections {
+			for _, f := range s.Fields {
+				add(item, f.Value)
+			}
+		}
+
+		for _, a := range item.Attachments() {
+			if a.ContentsSize > maxKeyAttachmentSize {
+				continue
+			}
+			data, err := a.Contents(vault)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "1pwd: warning: %s: %s\n", item.Data.Title, err)
+				continue
+			}
+			add(item, string(data))
+		}
+	}
+
+	return keys
+}
+
+// confirmSSHKey asks whether a signature may be made, using SSH_ASKPASS
+// when it is set and the terminal otherwise.
+func confirmSSHKey(key *sshagent.Key) bool {
+	prompt := fmt.Sprintf("Allow use of SSH key %q (%s)?", key.Comment, key.Type())
+
+	if askpass := os.Getenv("SSH_ASKPASS"); askpass != "" {
+		cmd := exec.Command(askpass, prompt)
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS_PROMPT=confirm")
+		return cmd.Run() == nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// doTOTP prints the one-time password of an item. The item is either looked
+// up by its UUID or title, or picked with the finder.
+//
+// On a terminal the code is followed by the seconds it remains valid,
+// otherwise only the code is printed. With waitFresh it first waits for the
+// next code when the current one is valid for less than waitFresh seconds.
+// With watch the code is refreshed in place until interrupted. The counter
+// of a HOTP is advanced and saved for every code.
+func doTOTP(vault *opvault.Vault, finder Finder, id, query string, next, watch bool, waitFresh int) {
+	var item *opvault.Item
+
+	if finder != nil {
+		var items []*opvault.Item
+		for _, i := range vault.All() {
+			if !i.Trashed && i.Category != opvault.TombstoneItem {
+				items = append(items, i)
+			}
+		}
+
+		var err error
+		item, err = finder(vault, query, items)
+		assert(err)
+		if item == nil {
+			return
+		}
+	} else {
+		var err error
+		item, err = vault.Find(id)
+		if os.IsNotExist(err) {
+			abortf("item %q not found", id)
+		}
+		assert(err)
+	}
+
+	err := item.Decrypt(vault)
+	assert(err)
+
+	otp, err := item.OTP()
+	if err != nil {
+		abortf("%s", err)
+	}
+
+	if otp.Type == "hotp" && (watch || waitFresh > 0) {
+		abortf("--watch and --wait-fresh only work with time based codes")
+	}
+
+	if otp.Type == "hotp" {
+		// A counter based code is only accepted once, so the counter is
+		// advanced and saved before the code is shown.
+		code := formatOTP(otp, time.Now(), next, isTerminal(os.Stdout))
+
+		err = otp.Advance()
+		assert(err)
+		err = vault.Update(item)
+		assert(err)
+		err = vault.Save()
+		assert(err)
+
+		fmt.Println(code)
+		return
+	}
+
+	if waitFresh > 0 {
+		now := time.Now()
+		code := otp.Code(now)
+		if left := code.Remaining(now); left < time.Duration(waitFresh)*time.Second {
+			fmt.Fprintf(os.Stderr, "waiting %ds for a fresh code\n", int((left+time.Second-1)/time.Second))
+			time.Sleep(left)
+		}
+	}
+
+	if !watch {
+		fmt.Println(formatOTP(otp, time.Now(), next, isTerminal(os.Stdout)))
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		fmt.Printf("\r%s\x1b[K", formatOTP(otp, time.Now(), next, true))
+
+		select {
+		case <-ticker.C:
+		case <-signals:
+			fmt.Println()
+			return
+		}
+	}
+}
+
+func formatOTP(otp *opvault.OTP, now time.Time, next, verbose bool) string {
+	code := otp.Code(now)
+
+	var nextCode string
+	if next {
+		nextCode = otp.Generate(code.Counter + 1)
+	}
+
+	if !verbose {
+		if next {
+			return code.Code + "\n" + nextCode
+		}
+		return code.Code
+	}
+
+	s := code.Code
+	if !code.Expires.IsZero() {
+		s += fmt.Sprintf("  %2ds", int((code.Remaining(now)+time.Second-1)/time.Second))
+	}
+	if next {
+		s += "  next " + nextCode
+	}
+	return s
+}
+
+// isTerminal reports whether f is a terminal (a character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,301 @@
+// Command docker-credential-1pwd is a docker credential helper which keeps
+// registry credentials as login items in a vault. As docker talks to the
+// helper over stdin, the vault must be unlocked in the 1pwd agent.
+//
+// The vault, profile and agent socket can be selected with the
+// ONEPWD_VAULT, ONEPWD_PROFILE and ONEPWD_AGENT_SOCK environment variables.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mattdenner/1pwd/pkg/agent"
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+const tag = "docker-credential"
+
+var errNotFound = errors.New("credentials not found in native keychain")
+
+type credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s get|store|erase|list\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "get":
+		err = doGet()
+	case "store":
+		err = doStore()
+	case "erase":
+		err = doErase()
+	case "list":
+		err = doList()
+	default:
+		err = fmt.Errorf("unknown operation %q", os.Args[1])
+	}
+
+	if err != nil {
+		// docker reads errors from stdout
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func doGet() error {
+	serverURL, err := readServerURL()
+	if err != nil {
+		return err
+	}
+
+	vault, err := openVault()
+	if err != nil {
+		return err
+	}
+
+	item, username, err := find(vault, serverURL, "", false)
+	if err != nil {
+		return err
+	}
+
+	secret, _ := item.Extract("password")
+
+	return json.NewEncoder(os.Stdout).Encode(credentials{
+		ServerURL: serverURL,
+		Username:  username,
+		Secret:    secret,
+	})
+}
+
+func doStore() error {
+	var creds credentials
+
+	err := json.NewDecoder(os.Stdin).Decode(&creds)
+	if err != nil {
+		return err
+	}
+
+	vault, err := openVault()
+	if err != nil {
+		return err
+	}
+
+	item, _, err := find(vault, creds.ServerURL, creds.Username, true)
+	if err == errNotFound {
+		item, err = opvault.NewItem(opvault.LoginItem)
+		if err != nil {
+			return err
+		}
+		item.Set("title", hostOf(creds.ServerURL))
+		item.Set("url", creds.ServerURL)
+		item.Set("username", creds.Username)
+		item.Set("password", creds.Secret)
+		item.Data.Tags = append(item.Data.Tags, tag)
+		err = vault.Add(item)
+	} else if err == nil {
+		if v, _ := item.Extract("password"); v == creds.Secret {
+			return nil
+		}
+		item.Set("password", creds.Secret)
+		err = vault.Update(item)
+	}
+	if err != nil {
+		return err
+	}
+
+	return vault.Save()
+}
+
+func doErase() error {
+	serverURL, err := readServerURL()
+	if err != nil {
+		return err
+	}
+
+	vault, err := openVault()
+	if err != nil {
+		return err
+	}
+
+	item, _, err := find(vault, serverURL, "", true)
+	if err != nil {
+		return err
+	}
+
+	err = vault.Trash(item)
+	if err != nil {
+		return err
+	}
+
+	return vault.Save()
+}
+
+func doList() error {
+	vault, err := openVault()
+	if err != nil {
+		return err
+	}
+
+	list := map[string]string{}
+	for _, item := range vault.All() {
+		if item.Trashed || item.Category != opvault.LoginItem || !item.HasTag(tag) {
+			continue
+		}
+		err = item.Decrypt(vault)
+		if err != nil {
+			return err
+		}
+		username, _ := item.Extract("username")
+		list[item.Data.URL] = username
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(list)
+}
+
+// find returns the login item (and its username) for the registry server.
+// Logins created by the helper, whose host and port match those of the
+// server exactly, come first. Unless own is set other logins for the host
+// (without a port or with the same one) are used too, so tokens saved with
+// the apps are found; store and erase never touch those.
+func find(vault *opvault.Vault, serverURL, username string, own bool) (*opvault.Item, string, error) {
+	host := hostOf(serverURL)
+	if host == "" {
+		return nil, "", fmt.Errorf("invalid server URL %q", serverURL)
+	}
+
+	var other []*opvault.Item
+	for _, item := range vault.All() {
+		if item.Trashed || item.Category != opvault.LoginItem {
+			continue
+		}
+		if item.HasTag(tag) {
+			if strings.EqualFold(hostOf(item.Data.URL), host) {
+				item, v, err := withUsername(vault, item, username)
+				if item != nil || err != nil {
+					return item, v, err
+				}
+			}
+		} else if !own && sameHost(hostOf(item.Data.URL), host) {
+			other = append(other, item)
+		}
+	}
+
+	for _, item := range other {
+		item, v, err := withUsername(vault, item, username)
+		if item != nil || err != nil {
+			return item, v, err
+		}
+	}
+
+	return nil, "", errNotFound
+}
+
+// withUsername decrypts the item and returns it and its username, unless a
+// username is given and the item has a different one.
+func withUsername(vault *opvault.Vault, item *opvault.Item, username string) (*opvault.Item, string, error) {
+	err := item.Decrypt(vault)
+	if err != nil {
+		return nil, "", err
+	}
+
+	v, _ := item.Extract("username")
+	if username != "" && v != username {
+		return nil, "", nil
+	}
+	return item, v, nil
+}
+
+// sameHost reports whether the host of a login is that of the registry. A
+// login without a port matches the registry on any port.
+func sameHost(login, registry string) bool {
+	if strings.EqualFold(login, registry) {
+		return true
+	}
+	if _, port, err := net.SplitHostPort(login); err == nil && port != "" {
+		return false
+	}
+	hostname, _, err := net.SplitHostPort(registry)
+	return err == nil && strings.EqualFold(login, hostname)
+}
+
+func hostOf(serverURL string) string {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "https://" + serverURL
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+func readServerURL() (string, error) {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+
+	serverURL := strings.TrimSpace(string(data))
+	if serverURL == "" {
+		return "", errors.New("missing server URL")
+	}
+
+	return serverURL, nil
+}
+
+func openVault() (*opvault.Vault, error) {
+	vaultPath := os.Getenv("ONEPWD_VAULT")
+	if vaultPath == "" {
+		vaults, err := opvault.LookupVaults()
+		if err != nil {
+			return nil, err
+		}
+		if len(vaults) == 0 {
+			return nil, errors.New("no vaults found")
+		}
+		vaultPath = vaults[0]
+	}
+
+	socket := os.Getenv("ONEPWD_AGENT_SOCK")
+	if socket == "" {
+		socket = agent.SocketPath()
+	}
+
+	profile := os.Getenv("ONEPWD_PROFILE")
+
+	keys, err := agent.Get(socket, vaultPath, profile)
+	if err != nil {
+		return nil, fmt.Errorf("vault is locked (run `1pwd agent` and `1pwd unlock`): %s", err)
+	}
+
+	vault, err := opvault.OpenWithOptions(vaultPath, "", &opvault.OpenOptions{Profile: profile, Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+
+	// docker only reads stdout, the warnings are for the user
+	for _, item := range vault.All() {
+		if !item.ValidHMAC() {
+			fmt.Fprintf(os.Stderr, "docker-credential-1pwd: warning: item %s: %s\n", item.UUID, opvault.ErrInvalidHMAC)
+		}
+	}
+
+	return vault, nil
+}
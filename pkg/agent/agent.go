@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+var (
+	ErrLocked      = errors.New("agent has no keys for the vault")
+	ErrUnsupported = errors.New("the agent is not supported on this platform")
+)
+
+// Agent keeps the unlocked keys of vault profiles in memory so they can be
+// opened without asking for the master password every time. All keys are
+// forgotten when the agent was idle for longer than its timeout.
+type Agent struct {
+	mu      sync.Mutex
+	keys    map[string]*opvault.Keys
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+type request struct {
+	Op      string        `json:"op"`
+	Vault   string        `json:"vault,omitempty"`
+	Profile string        `json:"profile,omitempty"`
+	Keys    *opvault.Keys `json:"keys,omitempty"`
+}
+
+type response struct {
+	Keys  *opvault.Keys `json:"keys,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+func New(timeout time.Duration) *Agent {
+	return &Agent{keys: map[string]*opvault.Keys{}, timeout: timeout}
+}
+
+// SocketPath returns the default path of the agent socket.
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("1pwd-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "1pwd")
+	}
+	return filepath.Join(dir, "agent.sock")
+}
+
+// Listen creates the agent socket in a directory which is only accessible
+// by the current user. A stale socket left behind by a previous agent is
+// removed. It fails on platforms where the credentials of the processes
+// connecting to the socket cannot be checked.
+func Listen(path string) (*net.UnixListener, error) {
+	if !peerCredentials {
+		return nil, ErrUnsupported
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkOwner(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	os.Remove(path)
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Serve accepts connections until the listener is closed.
+func (a *Agent) Serve(l *net.UnixListener) error {
+	a.touch()
+
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			return err
+		}
+
+		go a.handle(conn)
+	}
+}
+
+func (a *Agent) handle(conn *net.UnixConn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if err := CheckPeer(conn); err != nil {
+		json.NewEncoder(conn).Encode(response{Error: err.Error()})
+		return
+	}
+
+	var req request
+	err := json.NewDecoder(conn).Decode(&req)
+	if err != nil {
+		json.NewEncoder(conn).Encode(response{Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(conn).Encode(a.do(&req))
+}
+
+func (a *Agent) do(req *request) response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.touchLocked()
+
+	switch req.Op {
+	case "get":
+		keys := a.keys[keyFor(req.Vault, req.Profile)]
+		if keys == nil {
+			return response{Error: ErrLocked.Error()}
+		}
+		return response{Keys: keys}
+
+	case "put":
+		if req.Keys == nil {
+			return response{Error: "missing keys"}
+		}
+		a.keys[keyFor(req.Vault, req.Profile)] = req.Keys
+		return response{}
+
+	case "lock":
+		a.lockLocked()
+		return response{}
+
+	default:
+		return response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+	}
+}
+
+// Lock forgets all the keys.
+func (a *Agent) Lock() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lockLocked()
+}
+
+func (a *Agent) lockLocked() {
+	for id, keys := range a.keys {
+		for _, k := range [][]byte{keys.MasterEncKey, keys.MasterMacKey, keys.OverviewEncKey, keys.OverviewMacKey} {
+			for i := range k {
+				k[i] = 0
+			}
+		}
+		delete(a.keys, id)
+	}
+}
+
+func (a *Agent) touch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.touchLocked()
+}
+
+func (a *Agent) touchLocked() {
+	if a.timeout <= 0 {
+		return
+	}
+
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.timeout, a.Lock)
+	} else {
+		a.timer.Reset(a.timeout)
+	}
+}
+
+func keyFor(vault, profile string) string {
+	if profile == "" {
+		profile = "default"
+	}
+	return vault + "\x00" + profile
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/mattdenner/1pwd/pkg/opvault"
+)
+
+// Get asks the agent listening on socket for the keys of a vault profile.
+func Get(socket, vault, profile string) (*opvault.Keys, error) {
+	vault, err := filepath.Abs(vault)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := call(socket, &request{Op: "get", Vault: vault, Profile: profile})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Keys, nil
+}
+
+// Put hands the keys of a vault profile to the agent listening on socket.
+func Put(socket, vault, profile string, keys *opvault.Keys) error {
+	vault, err := filepath.Abs(vault)
+	if err != nil {
+		return err
+	}
+
+	_, err = call(socket, &request{Op: "put", Vault: vault, Profile: profile, Keys: keys})
+	return err
+}
+
+// Lock makes the agent listening on socket forget all its keys.
+func Lock(socket string) error {
+	_, err := call(socket, &request{Op: "lock"})
+	return err
+}
+
+// call sends a request to the agent. The keys are only handed to (or
+// accepted from) an agent of the current user: the socket must be in a
+// directory nobody else can access and the process listening on it must
+// run as the current user.
+func call(socket string, req *request) (*response, error) {
+	err := checkOwner(filepath.Dir(socket))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	conn := c.(*net.UnixConn)
+
+	err = CheckPeer(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp response
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error == ErrLocked.Error() {
+		return nil, ErrLocked
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkOwner makes sure the socket directory belongs to the current user
+// and is not accessible by anyone else.
+func checkOwner(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is not owned by the current user", dir)
+	}
+
+	if fi.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s is accessible by other users", dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+package agent
+
+func checkOwner(dir string) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build darwin || freebsd || dragonfly
+// +build darwin freebsd dragonfly
+
+package agent
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const peerCredentials = true
+
+const (
+	solLocal      = 0 // SOL_LOCAL
+	localPeerCred = 1 // LOCAL_PEERCRED
+)
+
+// xucred is the credential structure returned by LOCAL_PEERCRED. Only the
+// fields up to cr_uid are used; the rest leaves room for the groups.
+type xucred struct {
+	Version uint32
+	Uid     uint32
+	Ngroups int16
+	Groups  [16]uint32
+	_       [16]byte
+}
+
+// CheckPeer makes sure the process on the other end of the connection runs
+// as the same user as the agent.
+func CheckPeer(conn *net.UnixConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var (
+		cred    xucred
+		credErr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(cred))
+		_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, solLocal, localPeerCred,
+			uintptr(unsafe.Pointer(&cred)), uintptr(unsafe.Pointer(&size)), 0)
+		if errno != 0 {
+			credErr = errno
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+
+	if cred.Version != 0 || int(cred.Uid) != os.Getuid() {
+		return errors.New("permission denied")
+	}
+
+	return nil
+}
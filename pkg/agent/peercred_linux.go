@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+const peerCredentials = true
+
+// CheckPeer makes sure the process on the other end of the connection runs
+// as the same user as the agent.
+func CheckPeer(conn *net.UnixConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+
+	if int(cred.Uid) != os.Getuid() {
+		return errors.New("permission denied")
+	}
+
+	return nil
+}
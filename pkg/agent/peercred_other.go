@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!dragonfly
+
+package agent
+
+import (
+	"errors"
+	"net"
+)
+
+const peerCredentials = false
+
+// CheckPeer fails on this platform: the credentials of the peer cannot be
+// queried, so keys are never exchanged.
+func CheckPeer(conn *net.UnixConn) error {
+	return errors.New("checking the peer of a socket is not supported on this platform")
+}
@@ -0,0 +1,174 @@
+// Package clipboard copies text to the clipboard using one of the tools
+// commonly found on desktops and in terminal sessions.
+package clipboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var (
+	ErrNoBackend   = errors.New("clipboard: no clipboard available")
+	ErrUnsupported = errors.New("clipboard: reading is not supported")
+)
+
+// Backend is a way to access a clipboard.
+type Backend interface {
+	Name() string
+
+	// Copy replaces the contents of the clipboard.
+	Copy(data []byte) error
+
+	// Paste returns the contents of the clipboard or ErrUnsupported when the
+	// backend cannot read it.
+	Paste() ([]byte, error)
+
+	// Clear empties the clipboard.
+	Clear() error
+}
+
+// Names lists the names accepted by Lookup.
+var Names = []string{"auto", "wl-copy", "xclip", "xsel", "pbcopy", "tmux", "osc52"}
+
+// Lookup returns the named backend; "auto" (or an empty name) detects one.
+func Lookup(name string) (Backend, error) {
+	switch name {
+	case "", "auto":
+		return Detect()
+	case "wl-copy":
+		return &command{"wl-copy", []string{"wl-copy"}, []string{"wl-paste", "--no-newline"}, []string{"wl-copy", "--clear"}}, nil
+	case "xclip":
+		return &command{"xclip", []string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}, nil}, nil
+	case "xsel":
+		return &command{"xsel", []string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}, []string{"xsel", "--clipboard", "--delete"}}, nil
+	case "pbcopy":
+		return &command{"pbcopy", []string{"pbcopy"}, []string{"pbpaste"}, nil}, nil
+	case "tmux":
+		return &command{"tmux", []string{"tmux", "load-buffer", "-"}, []string{"tmux", "save-buffer", "-"}, []string{"tmux", "delete-buffer"}}, nil
+	case "osc52":
+		return osc52{}, nil
+	default:
+		return nil, fmt.Errorf("clipboard: unknown backend %q", name)
+	}
+}
+
+// Detect picks the backend matching the current session: Wayland, X11 and
+// macOS clipboards are preferred, then tmux buffers and finally OSC 52
+// escape sequences (which most terminal emulators forward to the local
+// clipboard, even over SSH).
+func Detect() (Backend, error) {
+	var names []string
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		names = append(names, "wl-copy")
+	}
+	if os.Getenv("DISPLAY") != "" {
+		names = append(names, "xclip", "xsel")
+	}
+	if runtime.GOOS == "darwin" {
+		names = append(names, "pbcopy")
+	}
+	if os.Getenv("TMUX") != "" {
+		names = append(names, "tmux")
+	}
+
+	for _, name := range names {
+		b, _ := Lookup(name)
+		if _, err := exec.LookPath(b.(*command).copy[0]); err == nil {
+			return b, nil
+		}
+	}
+
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		tty.Close()
+		return osc52{}, nil
+	}
+
+	return nil, ErrNoBackend
+}
+
+// command is a backend using external programs.
+type command struct {
+	name  string
+	copy  []string
+	paste []string
+	clear []string
+}
+
+func (c *command) Name() string { return c.name }
+
+func (c *command) Copy(data []byte) error {
+	cmd := exec.Command(c.copy[0], c.copy[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (c *command) Paste() ([]byte, error) {
+	cmd := exec.Command(c.paste[0], c.paste[1:]...)
+	return cmd.Output()
+}
+
+func (c *command) Clear() error {
+	if c.clear == nil {
+		return c.Copy(nil)
+	}
+
+	cmd := exec.Command(c.clear[0], c.clear[1:]...)
+	return cmd.Run()
+}
+
+// osc52 sets the clipboard of the terminal emulator with an OSC 52 escape
+// sequence. The clipboard cannot be read back.
+type osc52 struct{}
+
+func (osc52) Name() string { return "osc52" }
+
+func (osc52) Copy(data []byte) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(data) + "\x07"
+	if os.Getenv("TMUX") != "" {
+		// tmux only passes escape sequences on when they are wrapped.
+		seq = "\x1bPtmux;" + strings.Replace(seq, "\x1b", "\x1b\x1b", -1) + "\x1b\\"
+	}
+
+	_, err = tty.WriteString(seq)
+	return err
+}
+
+func (osc52) Paste() ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+func (o osc52) Clear() error {
+	return o.Copy(nil)
+}
+
+// ClearIf empties the clipboard if it still holds data with the given
+// SHA-256 sum, so content copied by someone else is left alone. It reports
+// whether the clipboard was cleared.
+func ClearIf(b Backend, sum []byte) (bool, error) {
+	data, err := b.Paste()
+	if err != nil {
+		return false, err
+	}
+
+	current := sha256.Sum256(data)
+	if !bytes.Equal(current[:], sum) {
+		return false, nil
+	}
+
+	return true, b.Clear()
+}
@@ -0,0 +1,167 @@
+package opvault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	opcldat = []byte("OPCLDAT")
+)
+
+// Attachment is a file attached to an item. Only the metadata is read when
+// the vault is opened; the contents are decrypted on demand.
+type Attachment struct {
+	UUID         string `json:"uuid"`
+	ItemUUID     string `json:"itemUUID"`
+	ContentsSize int64  `json:"contentsSize"`
+	External     bool   `json:"external"`
+	UpdatedAt    int64  `json:"updatedAt"`
+	CreatedAt    int64  `json:"createdAt"`
+	TxTimestamp  int64  `json:"txTimestamp"`
+	Overview     []byte `json:"overview"`
+
+	Data *AttachmentData `json:"-"`
+
+	path       string
+	headerSize int64
+	iconSize   int64
+}
+
+type AttachmentData struct {
+	Filename string `json:"filename,omitempty"`
+}
+
+// readAttachment reads the header and the metadata of an attachment file.
+// An attachment file is laid out as follows:
+//
+//	"OPCLDAT" | version (1) | metadata size (2) | junk (2) | icon size (4)
+//	metadata (JSON) | icon (opdata01) | contents (opdata01)
+func readAttachment(path string) (*Attachment, error) {
+	var (
+		header     [16]byte
+		attachment *Attachment
+	)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	_, err = io.ReadFull(f, header[:])
+	if err != nil {
+		return nil, errors.New("invalid attachment header length")
+	}
+	if !bytes.HasPrefix(header[:], opcldat) || header[7] != 1 {
+		return nil, errors.New("invalid attachment header")
+	}
+
+	metadata := make([]byte, binary.LittleEndian.Uint16(header[8:]))
+	_, err = io.ReadFull(f, metadata)
+	if err != nil {
+		return nil, errors.New("invalid attachment metadata length")
+	}
+
+	err = json.Unmarshal(metadata, &attachment)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.path = path
+	attachment.headerSize = int64(len(header) + len(metadata))
+	attachment.iconSize = int64(binary.LittleEndian.Uint32(header[12:]))
+
+	return attachment, nil
+}
+
+func (a *Attachment) decryptOverView(p *Profile) error {
+	dst, err := decrypt(nil, a.Overview, p.overviewEncKey, p.overviewMacKey)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(dst, &a.Data)
+}
+
+// Contents reads and decrypts the contents of the attachment.
+func (a *Attachment) Contents(v *Vault) ([]byte, error) {
+	item, err := v.Get(a.ItemUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.contents(item, v.profile)
+}
+
+func (a *Attachment) contents(item *Item, p *Profile) ([]byte, error) {
+	itemKey, err := item.itemKey(p)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := a.headerSize + a.iconSize
+	if offset > int64(len(data)) {
+		return nil, errors.New("invalid attachment icon length")
+	}
+
+	return decrypt(nil, data[offset:], itemKey[:32], itemKey[32:])
+}
+
+// loadAttachments reads the metadata of all the attachments in the profile
+// directory and links them to their items. Attachments of unknown items and
+// files which cannot be read or decrypted are ignored, so a single broken
+// file does not make the whole vault unusable; Verify reports them.
+func (v *Vault) loadAttachments() error {
+	paths, err := filepath.Glob(filepath.Join(v.dir, "*.attachment"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".attachment")
+		idx := strings.IndexByte(name, '_')
+		if idx < 0 {
+			continue
+		}
+
+		item, err := v.Get(name[:idx])
+		if err != nil {
+			continue
+		}
+
+		attachment, err := readAttachment(path)
+		if err != nil {
+			continue
+		}
+
+		err = attachment.decryptOverView(v.profile)
+		if err != nil {
+			continue
+		}
+
+		item.attachments = append(item.attachments, attachment)
+		sort.Sort(attachmentsByFilename(item.attachments))
+	}
+
+	return nil
+}
+
+type attachmentsByFilename []*Attachment
+
+func (s attachmentsByFilename) Len() int           { return len(s) }
+func (s attachmentsByFilename) Less(i, j int) bool { return s[i].Data.Filename < s[j].Data.Filename }
+func (s attachmentsByFilename) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
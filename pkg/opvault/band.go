@@ -31,6 +31,23 @@ func parseBand(data []byte) (Band, error) {
 		return nil, err
 	}
 
+	// keep the properties of every item as they are stored for verifying
+	// their HMAC.
+	var raw map[string]map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+	for id, item := range band {
+		if item == nil {
+			delete(band, id)
+			continue
+		}
+		item.raw = raw[id]
+	}
+
 	return band, nil
 }
 
@@ -43,3 +60,18 @@ func (b Band) decryptOverView(p *Profile) error {
 	}
 	return nil
 }
+
+// write stores the band. Properties of the items unknown to Item are
+// written back as they were read, so their HMACs stay valid.
+func (b Band) write(path string) error {
+	items := make(map[string]map[string]interface{}, len(b))
+	for id, item := range b {
+		props, err := item.properties()
+		if err != nil {
+			return err
+		}
+		items[id] = props
+	}
+
+	return writeJSONP(path, "ld(", ");", items)
+}
@@ -5,11 +5,15 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"hash"
+	"io"
+	"strings"
 )
 
 var (
@@ -126,3 +130,102 @@ func decryptKey(dst, src []byte, encKey, macKey []byte) ([]byte, error) {
 
 	return dst, nil
 }
+
+func encrypt(src []byte, encKey, macKey []byte) ([]byte, error) {
+	var (
+		padLen = aes.BlockSize - (len(src) % aes.BlockSize)
+		dst    = make([]byte, 32+padLen+len(src), 32+padLen+len(src)+32)
+		mac    hash.Hash
+	)
+
+	{ // write header
+		copy(dst, opdata01)
+		binary.LittleEndian.PutUint64(dst[8:], uint64(len(src)))
+		_, err := io.ReadFull(rand.Reader, dst[16:32])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	{ // prepend random padding
+		_, err := io.ReadFull(rand.Reader, dst[32:32+padLen])
+		if err != nil {
+			return nil, err
+		}
+		copy(dst[32+padLen:], src)
+	}
+
+	{
+		block, err := aes.NewCipher(encKey)
+		if err != nil {
+			return nil, err
+		}
+
+		mode := cipher.NewCBCEncrypter(block, dst[16:32])
+		mode.CryptBlocks(dst[32:], dst[32:])
+	}
+
+	{ // append mac
+		mac = hmac.New(sha256.New, macKey)
+		mac.Write(dst)
+		dst = mac.Sum(dst)
+	}
+
+	return dst, nil
+}
+
+func encryptKey(src []byte, encKey, macKey []byte) ([]byte, error) {
+	var (
+		dst = make([]byte, 16+len(src), 16+len(src)+32)
+		mac hash.Hash
+	)
+
+	if len(src)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid key length")
+	}
+
+	{ // write iv
+		_, err := io.ReadFull(rand.Reader, dst[:16])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	{
+		block, err := aes.NewCipher(encKey)
+		if err != nil {
+			return nil, err
+		}
+
+		mode := cipher.NewCBCEncrypter(block, dst[:16])
+		mode.CryptBlocks(dst[16:], src)
+	}
+
+	{ // append mac
+		mac = hmac.New(sha256.New, macKey)
+		mac.Write(dst)
+		dst = mac.Sum(dst)
+	}
+
+	return dst, nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+
+	_, err := io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func newUUID() (string, error) {
+	id, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(hex.EncodeToString(id)), nil
+}
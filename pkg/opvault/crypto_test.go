@@ -0,0 +1,65 @@
+package opvault
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key, err := randomBytes(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encKey, macKey := key[:32], key[32:]
+
+	for _, n := range []int{0, 1, 15, 16, 17, 100} {
+		src := bytes.Repeat([]byte{'x'}, n)
+
+		data, err := encrypt(src, encKey, macKey)
+		if err != nil {
+			t.Fatalf("encrypt(%d bytes): %s", n, err)
+		}
+
+		dst, err := decrypt(nil, data, encKey, macKey)
+		if err != nil {
+			t.Fatalf("decrypt(%d bytes): %s", n, err)
+		}
+		if !bytes.Equal(dst, src) {
+			t.Errorf("decrypt(encrypt(%q)) = %q", src, dst)
+		}
+
+		data[len(data)/2] ^= 1
+		if _, err := decrypt(nil, data, encKey, macKey); err == nil {
+			t.Errorf("decrypt(%d bytes) of changed data succeeded", n)
+		}
+	}
+}
+
+func TestEncryptKeyRoundTrip(t *testing.T) {
+	key, err := randomBytes(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := randomBytes(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := encryptKey(src, key[:32], key[32:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := decryptKey(nil, data, key[:32], key[32:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, src) {
+		t.Errorf("decryptKey(encryptKey(key)) = %x, want %x", dst, src)
+	}
+
+	data[0] ^= 1
+	if _, err := decryptKey(nil, data, key[:32], key[32:]); err == nil {
+		t.Error("decryptKey of changed data succeeded")
+	}
+}
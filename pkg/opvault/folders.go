@@ -4,18 +4,36 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"sort"
+	"strings"
 )
 
 type Folders map[string]*Folder
 
 type Folder struct {
-	UUID     string
-	Parent   string
-	Updated  int64
-	Created  int64
-	Tx       int64
-	Smart    bool
-	Overview []byte
+	UUID     string `json:"uuid"`
+	Parent   string `json:"parent,omitempty"`
+	Updated  int64  `json:"updated"`
+	Created  int64  `json:"created"`
+	Tx       int64  `json:"tx"`
+	Smart    bool   `json:"smart,omitempty"`
+	Overview []byte `json:"overview"`
+
+	Data *FolderData `json:"-"`
+
+	parent   *Folder
+	children []*Folder
+
+	predicate    predicate
+	predicateErr error
+}
+
+type FolderData struct {
+	Title string `json:"title,omitempty"`
+
+	// Smart folders store their predicate as an NSPredicate archived by
+	// NSKeyedArchiver.
+	Predicate []byte `json:"predicate_b64,omitempty"`
 }
 
 func parseFolders(data []byte) (Folders, error) {
@@ -43,3 +61,105 @@ func parseFolders(data []byte) (Folders, error) {
 
 	return folders, nil
 }
+
+func (f Folders) write(path string) error {
+	if f == nil {
+		f = Folders{}
+	}
+	return writeJSONP(path, "loadFolders(", ");", f)
+}
+
+func (f Folders) decryptOverView(p *Profile) error {
+	for _, folder := range f {
+		err := folder.decryptOverView(p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildTree links every folder to its parent and children.
+func (f Folders) buildTree() {
+	for _, folder := range f {
+		folder.parent = nil
+		folder.children = nil
+	}
+
+	for _, folder := range f {
+		if parent := f[folder.Parent]; parent != nil && parent != folder {
+			folder.parent = parent
+			parent.children = append(parent.children, folder)
+		}
+	}
+
+	for _, folder := range f {
+		sort.Sort(foldersByTitle(folder.children))
+	}
+}
+
+func (f *Folder) decryptOverView(p *Profile) error {
+	dst, err := decrypt(nil, f.Overview, p.overviewEncKey, p.overviewMacKey)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(dst, &f.Data)
+}
+
+func (f *Folder) ParentFolder() *Folder {
+	return f.parent
+}
+
+func (f *Folder) Children() []*Folder {
+	return f.children
+}
+
+// Path returns the titles of the folder and its ancestors joined by "/".
+func (f *Folder) Path() string {
+	var parts []string
+
+	for p, seen := f, map[*Folder]bool{}; p != nil && !seen[p]; p = p.parent {
+		seen[p] = true
+		parts = append([]string{p.Data.Title}, parts...)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// Contains reports whether other is the folder itself or one of its sub
+// folders.
+func (f *Folder) Contains(other *Folder) bool {
+	for p, seen := other, map[*Folder]bool{}; p != nil && !seen[p]; p = p.parent {
+		if p == f {
+			return true
+		}
+		seen[p] = true
+	}
+
+	return false
+}
+
+// smartPredicate decodes the predicate of a smart folder once.
+func (f *Folder) smartPredicate() (predicate, error) {
+	if f.predicate == nil && f.predicateErr == nil {
+		if f.Data == nil || len(f.Data.Predicate) == 0 {
+			f.predicateErr = errors.New("smart folder without predicate")
+		} else {
+			f.predicate, f.predicateErr = decodePredicate(f.Data.Predicate)
+		}
+	}
+	return f.predicate, f.predicateErr
+}
+
+type foldersByTitle []*Folder
+
+func (s foldersByTitle) Len() int           { return len(s) }
+func (s foldersByTitle) Less(i, j int) bool { return s[i].Data.Title < s[j].Data.Title }
+func (s foldersByTitle) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type foldersByPath []*Folder
+
+func (s foldersByPath) Len() int           { return len(s) }
+func (s foldersByPath) Less(i, j int) bool { return s[i].Path() < s[j].Path() }
+func (s foldersByPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -1,59 +1,253 @@
 package opvault
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"net/url"
 	"sort"
+	"strings"
+	"time"
 )
 
 type Item struct {
-	UUID     string
-	Category Category
-	Fave     int
-	Trashed  bool
-	Folder   string
-	O        []byte
-	K        []byte
-	D        []byte
-	HMAC     []byte
-	Tx       int64
-	Updated  int64
-	Created  int64
-
-	Data *struct {
-		UUID     string   `json:"uuid,omitempty"`
-		Category Category `json:"category,omitempty"`
-
-		// Overview
-		Title  string `json:"title,omitempty"`
-		URL    string `json:"url,omitempty"`
-		Domain string `json:"domain,omitempty"`
-		URLs   []struct {
-			U string `json:"url,omitempty"`
-			L string `json:"label,omitempty"`
-		}
-
-		// Data
-		BackupKeys [][]byte `json:"backupKeys"`
-		Password   string   `json:"password,omitempty"`
-		Fields     []struct {
-			Type        string `json:"type,omitempty"`
-			Name        string `json:"name,omitempty"`
-			Designation string `json:"designation,omitempty"`
-			Value       string `json:"value,omitempty"`
-		} `json:"fields,omitempty"`
-
-		// Sections
-		Sections []struct {
-			Fields []struct {
-				Name  string `json:"t,omitempty"`
-				Value string `json:"v,omitempty"`
+	UUID     string   `json:"uuid"`
+	Category Category `json:"category"`
+	Fave     int      `json:"fave,omitempty"`
+	Trashed  bool     `json:"trashed,omitempty"`
+	Folder   string   `json:"folder,omitempty"`
+	O        []byte   `json:"o,omitempty"`
+	K        []byte   `json:"k,omitempty"`
+	D        []byte   `json:"d,omitempty"`
+	HMAC     []byte   `json:"hmac"`
+	Tx       int64    `json:"tx"`
+	Updated  int64    `json:"updated"`
+	Created  int64    `json:"created"`
+
+	Data *ItemData `json:"-"`
+
+	raw         map[string]interface{}
+	overview    []byte
+	details     []byte
+	attachments []*Attachment
+	invalidHMAC bool
+}
+
+type ItemData struct {
+	UUID     string   `json:"uuid,omitempty"`
+	Category Category `json:"category,omitempty"`
+
+	// Overview
+	Title  string `json:"title,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	URLs   []ItemURL
+	Tags   []string `json:"tags,omitempty"`
+
+	// Data
+	BackupKeys [][]byte    `json:"backupKeys"`
+	Password   string      `json:"password,omitempty"`
+	NotesPlain string      `json:"notesPlain,omitempty"`
+	Fields     []ItemField `json:"fields,omitempty"`
+
+	PasswordHistory []PasswordChange `json:"passwordHistory,omitempty"`
+
+	// Sections
+	Sections []ItemSection `json:"sections,omitempty"`
+}
+
+type ItemURL struct {
+	U string `json:"u,omitempty"`
+	L string `json:"l,omitempty"`
+}
+
+// PasswordChange is a previous password of an item and the time (in
+// seconds since the epoch) at which it was replaced.
+type PasswordChange struct {
+	Value string `json:"value"`
+	Time  int64  `json:"time"`
+}
+
+type ItemField struct {
+	ID          string `json:"id,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Designation string `json:"designation,omitempty"`
+	Value       string `json:"value,omitempty"`
+}
+
+type ItemSection struct {
+	Name   string         `json:"name,omitempty"`
+	Title  string         `json:"title,omitempty"`
+	Fields []SectionField `json:"fields,omitempty"`
+}
+
+// SectionField is a field of a section. Values which are not stored as
+// strings (dates, months, addresses, ...) are available as text in Value;
+// their original form is written back unless Value is changed.
+type SectionField struct {
+	Kind       string
+	ID         string
+	Name       string
+	Value      string
+	Attributes json.RawMessage
+
+	rawValue json.RawMessage
+	rawText  string
+}
+
+type sectionFieldJSON struct {
+	Kind       string          `json:"k,omitempty"`
+	ID         string          `json:"n,omitempty"`
+	Name       string          `json:"t,omitempty"`
+	Value      json.RawMessage `json:"v,omitempty"`
+	Attributes json.RawMessage `json:"a,omitempty"`
+}
+
+func (f *SectionField) UnmarshalJSON(data []byte) error {
+	var j sectionFieldJSON
+
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*f = SectionField{Kind: j.Kind, ID: j.ID, Name: j.Name, Attributes: j.Attributes}
+
+	if len(j.Value) > 0 && json.Unmarshal(j.Value, &f.Value) != nil {
+		f.Value = formatSectionValue(f.Kind, j.Value)
+		f.rawValue = j.Value
+		f.rawText = f.Value
+	}
+
+	return nil
+}
+
+func (f SectionField) MarshalJSON() ([]byte, error) {
+	j := sectionFieldJSON{Kind: f.Kind, ID: f.ID, Name: f.Name, Attributes: f.Attributes}
+
+	if f.rawValue != nil && f.Value == f.rawText {
+		j.Value = f.rawValue
+	} else if f.Value != "" {
+		j.Value = parseSectionValue(f.Kind, f.Value)
+	}
+
+	return json.Marshal(j)
+}
+
+// parseSectionValue is the reverse of formatSectionValue. Values which
+// cannot be parsed are stored as strings.
+func parseSectionValue(kind, text string) json.RawMessage {
+	switch kind {
+	case "date":
+		if t, err := time.Parse("2006-01-02", text); err == nil {
+			return json.RawMessage(fmt.Sprint(t.Unix()))
+		}
+
+	case "monthYear":
+		var m, y int
+		if n, _ := fmt.Sscanf(text, "%d/%d", &m, &y); n == 2 && m >= 1 && m <= 12 {
+			return json.RawMessage(fmt.Sprint(y*100 + m))
+		}
+	}
+
+	data, _ := json.Marshal(text)
+	return data
+}
+
+// formatSectionValue turns a value which is not a string into text.
+func formatSectionValue(kind string, raw json.RawMessage) string {
+	switch kind {
+	case "date":
+		var t int64
+		if json.Unmarshal(raw, &t) == nil {
+			return time.Unix(t, 0).UTC().Format("2006-01-02")
+		}
+
+	case "monthYear":
+		var m int
+		if json.Unmarshal(raw, &m) == nil {
+			return fmt.Sprintf("%02d/%04d", m%100, m/100)
+		}
+
+	case "address":
+		var a struct {
+			Street  string `json:"street"`
+			City    string `json:"city"`
+			State   string `json:"state"`
+			Zip     string `json:"zip"`
+			Country string `json:"country"`
+		}
+		if json.Unmarshal(raw, &a) == nil {
+			var parts []string
+			for _, p := range []string{a.Street, a.City, strings.TrimSpace(a.State + " " + a.Zip), a.Country} {
+				if p != "" {
+					parts = append(parts, p)
+				}
 			}
-		} `json:"sections,omitempty"`
+			return strings.Join(parts, ", ")
+		}
+	}
+
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// itemOverview holds the keys of ItemData that are stored in the
+// overview (O) of an item.
+type itemOverview struct {
+	Title string    `json:"title,omitempty"`
+	URL   string    `json:"url,omitempty"`
+	URLs  []ItemURL `json:"URLs,omitempty"`
+	Tags  []string  `json:"tags,omitempty"`
+}
+
+var overviewKeys = []string{"title", "url", "URLs", "tags"}
+
+// itemDetails holds the keys of ItemData that are stored in the
+// details (D) of an item.
+type itemDetails struct {
+	Password   string        `json:"password,omitempty"`
+	NotesPlain string        `json:"notesPlain,omitempty"`
+	Fields     []ItemField   `json:"fields,omitempty"`
+	Sections   []ItemSection `json:"sections,omitempty"`
+
+	PasswordHistory []PasswordChange `json:"passwordHistory,omitempty"`
+}
+
+var detailsKeys = []string{"password", "notesPlain", "fields", "sections", "passwordHistory"}
+
+// NewItem returns a new, unsaved item of the given category with a fresh
+// UUID. Use Vault.Add to store it.
+func NewItem(category Category) (*Item, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
 	}
+
+	now := time.Now().Unix()
+
+	return &Item{
+		UUID:     id,
+		Category: category,
+		Tx:       now,
+		Updated:  now,
+		Created:  now,
+		Data:     &ItemData{Category: category},
+	}, nil
 }
 
 func (i *Item) decryptOverView(p *Profile) error {
+	if i.Category == TombstoneItem && len(i.O) == 0 {
+		i.Data = &ItemData{UUID: i.UUID, Category: i.Category}
+		return nil
+	}
+
 	dst, err := decrypt(nil, i.O, p.overviewEncKey, p.overviewMacKey)
 	if err != nil {
 		return err
@@ -64,6 +258,7 @@ func (i *Item) decryptOverView(p *Profile) error {
 		return err
 	}
 
+	i.overview = dst
 	i.Data.UUID = i.UUID
 	i.Data.Category = i.Category
 
@@ -81,8 +276,48 @@ func (i *Item) Decrypt(v *Vault) error {
 	return i.decryptData(v.profile)
 }
 
+// Attachments returns the files attached to the item sorted by their
+// filename.
+func (i *Item) Attachments() []*Attachment {
+	return i.attachments
+}
+
+// HasTag reports whether the item is tagged with tag.
+func (i *Item) HasTag(tag string) bool {
+	for _, t := range i.Data.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordChanged returns when the password of the (decrypted) item was
+// last changed according to its password history. Without a history the
+// time the item was last updated is returned.
+func (i *Item) PasswordChanged() time.Time {
+	var last int64
+	for _, c := range i.Data.PasswordHistory {
+		if c.Time > last {
+			last = c.Time
+		}
+	}
+	if last == 0 {
+		last = i.Updated
+	}
+	return time.Unix(last, 0)
+}
+
+func (i *Item) itemKey(p *Profile) ([]byte, error) {
+	return decryptKey(nil, i.K, p.masterEncKey, p.masterMacKey)
+}
+
 func (i *Item) decryptData(p *Profile) error {
-	dstKey, err := decryptKey(nil, i.K, p.masterEncKey, p.masterMacKey)
+	if i.Category == TombstoneItem && len(i.D) == 0 {
+		return nil
+	}
+
+	dstKey, err := i.itemKey(p)
 	if err != nil {
 		return err
 	}
@@ -97,6 +332,8 @@ func (i *Item) decryptData(p *Profile) error {
 		return err
 	}
 
+	i.details = dst
+
 	// var buf bytes.Buffer
 	// json.Indent(&buf, dst, "", "  ")
 	// fmt.Fprintf(os.Stderr, "data: %s\n", buf.String())
@@ -104,6 +341,222 @@ func (i *Item) decryptData(p *Profile) error {
 	return nil
 }
 
+// encrypt (re)encrypts the overview and details of the item, generating a
+// new item key when the item does not have one yet, and updates its HMAC.
+// The details are only rewritten when they were decrypted before or when
+// the item is new.
+func (i *Item) encrypt(p *Profile) error {
+	var (
+		itemKey []byte
+		err     error
+	)
+
+	if i.Data == nil {
+		i.Data = &ItemData{}
+	}
+
+	if len(i.K) == 0 {
+		itemKey, err = randomBytes(64)
+		if err != nil {
+			return err
+		}
+
+		i.K, err = encryptKey(itemKey, p.masterEncKey, p.masterMacKey)
+		if err != nil {
+			return err
+		}
+
+		i.D = nil
+	} else {
+		itemKey, err = i.itemKey(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	overview, err := mergeJSON(i.overview, itemOverview{
+		Title: i.Data.Title,
+		URL:   i.Data.URL,
+		URLs:  i.Data.URLs,
+		Tags:  i.Data.Tags,
+	}, overviewKeys)
+	if err != nil {
+		return err
+	}
+
+	i.O, err = encrypt(overview, p.overviewEncKey, p.overviewMacKey)
+	if err != nil {
+		return err
+	}
+	i.overview = overview
+
+	if i.details != nil || len(i.D) == 0 {
+		details, err := mergeJSON(i.details, itemDetails{
+			Password:   i.Data.Password,
+			NotesPlain: i.Data.NotesPlain,
+			Fields:     i.Data.Fields,
+			Sections:   i.Data.Sections,
+
+			PasswordHistory: i.Data.PasswordHistory,
+		}, detailsKeys)
+		if err != nil {
+			return err
+		}
+
+		i.D, err = encrypt(details, itemKey[:32], itemKey[32:])
+		if err != nil {
+			return err
+		}
+		i.details = details
+	}
+
+	i.HMAC, err = i.computeHMAC(p)
+	i.invalidHMAC = false
+	if err != nil {
+		return err
+	}
+
+	i.raw, err = i.properties()
+	return err
+}
+
+// itemKeys are the properties of an item in a band file which are held by
+// Item; all other properties are preserved as they were read.
+var itemKeys = map[string]bool{
+	"uuid": true, "category": true, "fave": true, "trashed": true,
+	"folder": true, "o": true, "k": true, "d": true, "hmac": true,
+	"tx": true, "updated": true, "created": true,
+}
+
+// properties returns the properties of the item as they are stored in the
+// band file: those of Item merged into the ones the item was read with.
+func (i *Item) properties() (map[string]interface{}, error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+
+	var props map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&props)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range i.raw {
+		if !itemKeys[k] {
+			props[k] = v
+		}
+	}
+
+	return props, nil
+}
+
+// computeHMAC calculates the HMAC-SHA256 over all the properties of the
+// item (except the hmac itself) as they are stored in the band file.
+func (i *Item) computeHMAC(p *Profile) ([]byte, error) {
+	props, err := i.properties()
+	if err != nil {
+		return nil, err
+	}
+
+	return itemHMAC(props, p.overviewMacKey), nil
+}
+
+// ValidHMAC reports whether the HMAC of the item matched its properties
+// when the vault was opened. An item with an invalid HMAC was changed by
+// someone without the keys of the vault, or is corrupt.
+func (i *Item) ValidHMAC() bool {
+	return !i.invalidHMAC
+}
+
+// verifyHMAC reports whether the HMAC of the item matches its properties.
+func (i *Item) verifyHMAC(p *Profile) bool {
+	var (
+		expected []byte
+		err      error
+	)
+
+	if i.raw != nil {
+		expected = itemHMAC(i.raw, p.overviewMacKey)
+	} else {
+		expected, err = i.computeHMAC(p)
+		if err != nil {
+			return false
+		}
+	}
+
+	return hmac.Equal(expected, i.HMAC)
+}
+
+func itemHMAC(fields map[string]interface{}, macKey []byte) []byte {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k == "hmac" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha256.New, macKey)
+	for _, k := range keys {
+		mac.Write([]byte(k))
+		switch v := fields[k].(type) {
+		case string:
+			mac.Write([]byte(v))
+		case json.Number:
+			mac.Write([]byte(v.String()))
+		case bool:
+			if v {
+				mac.Write([]byte("1"))
+			} else {
+				mac.Write([]byte("0"))
+			}
+		}
+	}
+
+	return mac.Sum(nil)
+}
+
+// mergeJSON overwrites the keys of the raw JSON object with those of v.
+// Keys which are missing from (the JSON encoding of) v are removed. All
+// other keys of raw are preserved as is.
+func mergeJSON(raw []byte, v interface{}, keys []string) ([]byte, error) {
+	var dst, src map[string]json.RawMessage
+
+	if len(raw) > 0 {
+		err := json.Unmarshal(raw, &dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dst == nil {
+		dst = make(map[string]json.RawMessage)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		if val, f := src[k]; f {
+			dst[k] = val
+		} else {
+			delete(dst, k)
+		}
+	}
+
+	return json.Marshal(dst)
+}
+
 func (i *Item) Extract(field string) (string, bool) {
 	var (
 		v string
@@ -145,6 +598,24 @@ func (i *Item) Extract(field string) (string, bool) {
 		}
 		return v, f
 
+	case "notes":
+		if !f && i.Data.NotesPlain != "" {
+			v, f = i.Data.NotesPlain, true
+		}
+		if !f {
+			v, f = i.extractFieldByName(field)
+		}
+		return v, f
+
+	case "One-Time Password", "totp":
+		if !f {
+			v, f = i.extractFieldByName(field)
+		}
+		if !f {
+			v, f = i.otpValue()
+		}
+		return v, f
+
 	default:
 		if !f {
 			v, f = i.extractFieldByDesignation(field)
@@ -157,6 +628,102 @@ func (i *Item) Extract(field string) (string, bool) {
 	}
 }
 
+// Set changes the value of a field, using the same field names as Extract.
+// The title and url are stored in the overview, all other fields in the
+// details, so the item must be decrypted before changing those. Username
+// and password fields are created when missing; for any other field false
+// is returned when it does not exist.
+func (i *Item) Set(field, value string) bool {
+	switch field {
+
+	case "title":
+		i.Data.Title = value
+		return true
+
+	case "url":
+		if len(i.Data.URLs) > 0 && i.Data.URLs[0].U == i.Data.URL {
+			i.Data.URLs[0].U = value
+		}
+		i.Data.URL = value
+		return true
+
+	case "username":
+		if i.setFieldByDesignation("username", value) ||
+			i.setFieldByName("username", value) ||
+			i.setFieldByName("login", value) {
+			return true
+		}
+		i.Data.Fields = append(i.Data.Fields, ItemField{
+			Type:        "T",
+			Name:        "username",
+			Designation: "username",
+			Value:       value,
+		})
+		return true
+
+	case "password":
+		if old, f := i.Extract("password"); f && old != "" && old != value {
+			i.Data.PasswordHistory = append(i.Data.PasswordHistory, PasswordChange{old, time.Now().Unix()})
+		}
+
+		if i.Data.Password != "" || i.Category == PasswordItem {
+			i.Data.Password = value
+			return true
+		}
+		if i.setFieldByDesignation("password", value) ||
+			i.setFieldByName("password", value) {
+			return true
+		}
+		i.Data.Fields = append(i.Data.Fields, ItemField{
+			Type:        "P",
+			Name:        "password",
+			Designation: "password",
+			Value:       value,
+		})
+		return true
+
+	case "notes":
+		i.Data.NotesPlain = value
+		return true
+
+	default:
+		return i.setFieldByDesignation(field, value) ||
+			i.setFieldByName(field, value)
+
+	}
+}
+
+func (i *Item) setFieldByName(field, value string) bool {
+	for idx := range i.Data.Fields {
+		if i.Data.Fields[idx].Name == field {
+			i.Data.Fields[idx].Value = value
+			return true
+		}
+	}
+	for sidx := range i.Data.Sections {
+		s := &i.Data.Sections[sidx]
+		for idx := range s.Fields {
+			if s.Fields[idx].Name == field {
+				s.Fields[idx].Value = value
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (i *Item) setFieldByDesignation(field, value string) bool {
+	for idx := range i.Data.Fields {
+		if i.Data.Fields[idx].Designation == field {
+			i.Data.Fields[idx].Value = value
+			return true
+		}
+	}
+
+	return false
+}
+
 func (i *Item) extractFieldByName(field string) (string, bool) {
 	for _, f := range i.Data.Fields {
 		if f.Name == field {
@@ -0,0 +1,93 @@
+package opvault
+
+import (
+	"net/url"
+	"strings"
+)
+
+// URLs returns all the URLs of the item (the main URL first).
+func (i *Item) URLs() []string {
+	var urls []string
+
+	if i.Data.URL != "" {
+		urls = append(urls, i.Data.URL)
+	}
+	for _, u := range i.Data.URLs {
+		if u.U != "" && u.U != i.Data.URL {
+			urls = append(urls, u.U)
+		}
+	}
+
+	return urls
+}
+
+// MatchURL reports whether one of the URLs of the item points at the same
+// host as u. When u has a scheme, a port or a path these must match too
+// (the path of u must be below the path of the item URL).
+func (i *Item) MatchURL(u *url.URL) bool {
+	for _, s := range i.URLs() {
+		if matchURL(parseLooseURL(s), u) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchURL(item, u *url.URL) bool {
+	if item == nil || item.Host == "" {
+		return false
+	}
+
+	if !strings.EqualFold(item.Hostname(), u.Hostname()) {
+		return false
+	}
+
+	if u.Scheme != "" && item.Scheme != "" && !strings.EqualFold(u.Scheme, item.Scheme) {
+		return false
+	}
+
+	if u.Port() != "" && item.Port() != "" && u.Port() != item.Port() {
+		return false
+	}
+
+	if p := strings.Trim(item.Path, "/"); p != "" && u.Path != "" {
+		up := strings.Trim(u.Path, "/")
+		if up != p && !strings.HasPrefix(up, p+"/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseLooseURL parses URLs as they are entered in the apps, which often
+// lack a scheme (like "example.com/login").
+func parseLooseURL(s string) *url.URL {
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil
+	}
+
+	return u
+}
+
+// FindByURL returns all the login items (not in the trash) which match u.
+func (v *Vault) FindByURL(u *url.URL) []*Item {
+	var results []*Item
+
+	for _, item := range v.All() {
+		if item.Trashed || item.Category != LoginItem {
+			continue
+		}
+		if item.MatchURL(u) {
+			results = append(results, item)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,311 @@
+package opvault
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoOTP = errors.New("item has no one-time password")
+
+// OTP generates one-time passwords (RFC 4226 and RFC 6238) as described by
+// an otpauth:// URI.
+type OTP struct {
+	Type      string // "totp" or "hotp"
+	Issuer    string
+	Account   string
+	Secret    []byte
+	Algorithm string // "SHA1", "SHA256" or "SHA512"
+	Digits    int
+	Period    int    // seconds per code (TOTP)
+	Counter   uint64 // next counter (HOTP)
+
+	// field is the value of the item field the OTP was read from.
+	field *string
+}
+
+// OTPCode is a generated one-time password. Start and Expires are zero for
+// HOTP codes.
+type OTPCode struct {
+	Code    string
+	Counter uint64
+	Start   time.Time
+	Expires time.Time
+}
+
+// Remaining returns how long the code is still valid.
+func (c *OTPCode) Remaining(now time.Time) time.Duration {
+	if c.Expires.IsZero() || now.After(c.Expires) {
+		return 0
+	}
+	return c.Expires.Sub(now)
+}
+
+// ParseOTP parses an otpauth://totp/ or otpauth://hotp/ URI. A bare base32
+// secret is accepted as well and describes a TOTP with the defaults (SHA1,
+// 6 digits, 30 seconds).
+func ParseOTP(s string) (*OTP, error) {
+	s = strings.TrimSpace(s)
+
+	o := &OTP{Type: "totp", Algorithm: "SHA1", Digits: 6, Period: 30}
+
+	if !strings.HasPrefix(strings.ToLower(s), "otpauth:") {
+		secret, err := decodeOTPSecret(s)
+		if err != nil {
+			return nil, err
+		}
+		o.Secret = secret
+		return o, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Type = strings.ToLower(u.Host)
+	if o.Type != "totp" && o.Type != "hotp" {
+		return nil, fmt.Errorf("otp: unsupported type %q", u.Host)
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.IndexByte(label, ':'); i >= 0 {
+		o.Issuer, label = label[:i], strings.TrimSpace(label[i+1:])
+	}
+	o.Account = label
+
+	q := u.Query()
+
+	if issuer := q.Get("issuer"); issuer != "" {
+		o.Issuer = issuer
+	}
+
+	o.Secret, err = decodeOTPSecret(q.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	if v := q.Get("algorithm"); v != "" {
+		o.Algorithm = strings.ToUpper(v)
+		if o.hash() == nil {
+			return nil, fmt.Errorf("otp: unsupported algorithm %q", v)
+		}
+	}
+
+	if v := q.Get("digits"); v != "" {
+		o.Digits, err = strconv.Atoi(v)
+		if err != nil || o.Digits < 6 || o.Digits > 8 {
+			return nil, fmt.Errorf("otp: invalid digits %q", v)
+		}
+	}
+
+	if v := q.Get("period"); v != "" {
+		o.Period, err = strconv.Atoi(v)
+		if err != nil || o.Period <= 0 {
+			return nil, fmt.Errorf("otp: invalid period %q", v)
+		}
+	}
+
+	if v := q.Get("counter"); v != "" {
+		o.Counter, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("otp: invalid counter %q", v)
+		}
+	} else if o.Type == "hotp" {
+		return nil, errors.New("otp: hotp without counter")
+	}
+
+	return o, nil
+}
+
+func decodeOTPSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Replace(s, " ", "", -1))
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return nil, errors.New("otp: missing secret")
+	}
+
+	if n := len(s) % 8; n != 0 {
+		s += strings.Repeat("=", 8-n)
+	}
+
+	secret, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("otp: invalid secret")
+	}
+	return secret, nil
+}
+
+func (o *OTP) hash() func() hash.Hash {
+	switch o.Algorithm {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// Generate returns the code for a counter value (or TOTP time step).
+func (o *OTP) Generate(counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(o.hash(), o.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < o.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", o.Digits, value%mod)
+}
+
+// Code returns the code of a TOTP at time t, or the code for the current
+// counter of a HOTP.
+func (o *OTP) Code(t time.Time) *OTPCode {
+	if o.Type == "hotp" {
+		return &OTPCode{Code: o.Generate(o.Counter), Counter: o.Counter}
+	}
+
+	period := int64(o.Period)
+	step := uint64(t.Unix() / period)
+	start := time.Unix(int64(step)*period, 0)
+
+	return &OTPCode{
+		Code:    o.Generate(step),
+		Counter: step,
+		Start:   start,
+		Expires: start.Add(time.Duration(period) * time.Second),
+	}
+}
+
+// Advance moves a HOTP on to the next counter after its code was used, as
+// a code is only accepted once. When the OTP was read from an item the
+// counter is stored in its field; the item must then be saved with
+// Vault.Update.
+func (o *OTP) Advance() error {
+	if o.Type != "hotp" {
+		return errors.New("otp: only counter based codes can be advanced")
+	}
+
+	o.Counter++
+
+	if o.field == nil {
+		return nil
+	}
+
+	u, err := url.Parse(strings.TrimSpace(*o.field))
+	if err != nil {
+		return err
+	}
+
+	q := u.Query()
+	q.Set("counter", strconv.FormatUint(o.Counter, 10))
+	u.RawQuery = q.Encode()
+	*o.field = u.String()
+
+	return nil
+}
+
+// Label returns "Issuer:Account" (or whichever of them is set).
+func (o *OTP) Label() string {
+	switch {
+	case o.Issuer != "" && o.Account != "":
+		return o.Issuer + ":" + o.Account
+	case o.Issuer != "":
+		return o.Issuer
+	default:
+		return o.Account
+	}
+}
+
+// IsOTP reports whether the field holds a one-time password.
+func (f *SectionField) IsOTP() bool {
+	return f.Kind == "totp" ||
+		strings.HasPrefix(f.ID, "TOTP_") ||
+		f.Name == "One-Time Password" ||
+		strings.HasPrefix(strings.ToLower(f.Value), "otpauth:")
+}
+
+// otpValue returns the value of the first one-time password field of the
+// item, wherever it is stored.
+func (i *Item) otpValue() (string, bool) {
+	for _, f := range i.Data.Fields {
+		if strings.HasPrefix(strings.ToLower(f.Value), "otpauth:") {
+			return f.Value, true
+		}
+	}
+	for _, s := range i.Data.Sections {
+		for _, f := range s.Fields {
+			if f.IsOTP() && f.Value != "" {
+				return f.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// OTPs returns all the one-time passwords of the (decrypted) item.
+func (i *Item) OTPs() ([]*OTP, error) {
+	var otps []*OTP
+
+	for j := range i.Data.Fields {
+		f := &i.Data.Fields[j]
+		if strings.HasPrefix(strings.ToLower(f.Value), "otpauth:") {
+			o, err := ParseOTP(f.Value)
+			if err != nil {
+				return nil, err
+			}
+			o.field = &f.Value
+			otps = append(otps, o)
+		}
+	}
+
+	for _, s := range i.Data.Sections {
+		for j := range s.Fields {
+			f := &s.Fields[j]
+			if !f.IsOTP() || f.Value == "" {
+				continue
+			}
+			o, err := ParseOTP(f.Value)
+			if err != nil {
+				return nil, err
+			}
+			o.field = &f.Value
+			otps = append(otps, o)
+		}
+	}
+
+	return otps, nil
+}
+
+// OTP returns the first one-time password of the (decrypted) item.
+func (i *Item) OTP() (*OTP, error) {
+	otps, err := i.OTPs()
+	if err != nil {
+		return nil, err
+	}
+	if len(otps) == 0 {
+		return nil, ErrNoOTP
+	}
+	return otps[0], nil
+}
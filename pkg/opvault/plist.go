@@ -0,0 +1,242 @@
+package opvault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"unicode/utf16"
+)
+
+var (
+	bplistMagic = []byte("bplist00")
+
+	errInvalidPlist = errors.New("invalid binary property list")
+)
+
+// plistUID is a reference to another object of a keyed archive.
+type plistUID uint64
+
+// bplist is a parsed binary property list (as written by NSKeyedArchiver).
+// Objects are decoded to nil, bool, int64, float64, string, []byte,
+// plistUID, []interface{} and map[string]interface{}.
+type bplist struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+	decoded int
+}
+
+// parseBinaryPlist returns the top object of a binary property list.
+func parseBinaryPlist(data []byte) (interface{}, error) {
+	if len(data) < len(bplistMagic)+32 || !bytes.HasPrefix(data, bplistMagic) {
+		return nil, errInvalidPlist
+	}
+
+	// The trailer: 6 unused bytes, the size of the offsets and of the
+	// object references, the number of objects, the top object and where
+	// the offset table starts.
+	trailer := data[len(data)-32:]
+	var (
+		offsetSize  = int(trailer[6])
+		refSize     = int(trailer[7])
+		numObjects  = binary.BigEndian.Uint64(trailer[8:])
+		topObject   = binary.BigEndian.Uint64(trailer[16:])
+		tableOffset = binary.BigEndian.Uint64(trailer[24:])
+	)
+
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 ||
+		numObjects > uint64(len(data)) || topObject >= numObjects ||
+		tableOffset > uint64(len(data)-32) || numObjects*uint64(offsetSize) > uint64(len(data)-32)-tableOffset {
+		return nil, errInvalidPlist
+	}
+
+	p := &bplist{data: data[:len(data)-32], refSize: refSize}
+	for i := uint64(0); i < numObjects; i++ {
+		off := tableOffset + i*uint64(offsetSize)
+		p.offsets = append(p.offsets, readUint(data[off:off+uint64(offsetSize)]))
+	}
+
+	return p.object(topObject, 0)
+}
+
+func (p *bplist) object(ref uint64, depth int) (interface{}, error) {
+	// Containers may refer to themselves or share their elements, so the
+	// depth and the number of decoded objects are limited.
+	p.decoded++
+	if ref >= uint64(len(p.offsets)) || depth > 64 || p.decoded > 1<<16 {
+		return nil, errInvalidPlist
+	}
+	off := p.offsets[ref]
+	if off >= uint64(len(p.data)) {
+		return nil, errInvalidPlist
+	}
+
+	marker := p.data[off]
+	kind, info := marker>>4, int(marker&0x0f)
+	off++
+
+	switch kind {
+	case 0x0:
+		switch marker {
+		case 0x00:
+			return nil, nil
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+
+	case 0x1:
+		b, err := p.bytes(off, 1<<uint(info))
+		if err != nil || len(b) > 8 {
+			return nil, errInvalidPlist
+		}
+		return int64(readUint(b)), nil
+
+	case 0x2:
+		b, err := p.bytes(off, 1<<uint(info))
+		if err != nil {
+			return nil, err
+		}
+		switch len(b) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+
+	case 0x4, 0x5:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.bytes(off, n)
+		if err != nil {
+			return nil, err
+		}
+		if kind == 0x5 {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+
+	case 0x6:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.bytes(off, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		units := make([]uint16, n)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+
+	case 0x8:
+		b, err := p.bytes(off, info+1)
+		if err != nil || len(b) > 8 {
+			return nil, errInvalidPlist
+		}
+		return plistUID(readUint(b)), nil
+
+	case 0xa:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(off, n)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, n)
+		for i, r := range refs {
+			if array[i], err = p.object(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+
+	case 0xd:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(off, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			s, ok := key.(string)
+			if !ok {
+				return nil, errInvalidPlist
+			}
+			if dict[s], err = p.object(refs[n+i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+
+	return nil, errInvalidPlist
+}
+
+// count returns the number of elements of an object and where they start.
+// Counts of 15 and more are stored in an integer object after the marker.
+func (p *bplist) count(info int, off uint64) (int, uint64, error) {
+	if info != 0x0f {
+		return info, off, nil
+	}
+
+	b, err := p.bytes(off, 1)
+	if err != nil || b[0]>>4 != 0x1 {
+		return 0, 0, errInvalidPlist
+	}
+	size := 1 << uint(b[0]&0x0f)
+	b, err = p.bytes(off+1, size)
+	if err != nil || size > 8 {
+		return 0, 0, errInvalidPlist
+	}
+
+	n := readUint(b)
+	if n > uint64(len(p.data)) {
+		return 0, 0, errInvalidPlist
+	}
+	return int(n), off + 1 + uint64(size), nil
+}
+
+func (p *bplist) refs(off uint64, n int) ([]uint64, error) {
+	b, err := p.bytes(off, n*p.refSize)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(b[i*p.refSize : (i+1)*p.refSize])
+	}
+	return refs, nil
+}
+
+func (p *bplist) bytes(off uint64, n int) ([]byte, error) {
+	if n < 0 || off > uint64(len(p.data)) || uint64(n) > uint64(len(p.data))-off {
+		return nil, errInvalidPlist
+	}
+	return p.data[off : off+uint64(n)], nil
+}
+
+// readUint reads a big endian unsigned integer of up to 8 bytes.
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -0,0 +1,486 @@
+package opvault
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A predicate selects the items of a smart folder. Smart folders store an
+// NSPredicate archived by NSKeyedArchiver; the comparisons and compound
+// predicates the apps build are decoded, as long as they only look at
+// the title, category, tags, URL or trashed state of an item.
+type predicate interface {
+	match(item *Item) bool
+}
+
+// NSPredicateOperatorType and NSComparisonPredicateOptions.
+const (
+	opLessThan = iota
+	opLessThanOrEqual
+	opGreaterThan
+	opGreaterThanOrEqual
+	opEqual
+	opNotEqual
+	opMatches
+	opLike
+	opBeginsWith
+	opEndsWith
+	opIn
+	opContains = 99
+
+	optCaseInsensitive = 1
+)
+
+// NSComparisonPredicateModifier.
+const (
+	modDirect = iota
+	modAll
+	modAny
+)
+
+type compoundPredicate struct {
+	kind int // NSCompoundPredicateType: not, and, or
+	subs []predicate
+}
+
+func (p *compoundPredicate) match(item *Item) bool {
+	switch p.kind {
+	case 0:
+		return !p.subs[0].match(item)
+	case 1:
+		for _, s := range p.subs {
+			if !s.match(item) {
+				return false
+			}
+		}
+		return true
+	default:
+		for _, s := range p.subs {
+			if s.match(item) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type constantPredicate bool
+
+func (p constantPredicate) match(*Item) bool { return bool(p) }
+
+type comparisonPredicate struct {
+	key      string
+	op       int
+	modifier int
+	negate   bool
+	value    interface{}
+	re       *regexp.Regexp // for matches and like
+	fold     bool
+}
+
+func (p *comparisonPredicate) match(item *Item) bool {
+	var values []interface{}
+
+	switch p.key {
+	case "title":
+		values = []interface{}{item.Data.Title}
+	case "url":
+		values = []interface{}{item.Data.URL}
+	case "domain":
+		values = []interface{}{item.Data.Domain}
+	case "category":
+		values = []interface{}{string(item.Category)}
+	case "trashed":
+		values = []interface{}{item.Trashed}
+	case "tags":
+		for _, t := range item.Data.Tags {
+			values = append(values, t)
+		}
+		// "tags CONTAINS x" tests the membership of x.
+		if p.modifier == modDirect {
+			for _, v := range values {
+				if p.compare(v, opEqual) {
+					return !p.negate
+				}
+			}
+			return p.negate
+		}
+	}
+
+	matched := p.modifier == modAll
+	for _, v := range values {
+		if p.compare(v, p.op) != matched {
+			matched = !matched
+			break
+		}
+	}
+
+	return matched != p.negate
+}
+
+func (p *comparisonPredicate) compare(v interface{}, op int) bool {
+	switch v := v.(type) {
+	case bool:
+		b, ok := p.value.(bool)
+		if !ok {
+			n, isInt := p.value.(int64)
+			b, ok = n != 0, isInt
+		}
+		return ok && (op == opEqual) == (v == b)
+
+	case string:
+		if op == opMatches || op == opLike {
+			return p.re.MatchString(v)
+		}
+		if op == opIn {
+			return p.in(v)
+		}
+
+		s, ok := p.value.(string)
+		if !ok {
+			return false
+		}
+		if p.fold {
+			v, s = strings.ToLower(v), strings.ToLower(s)
+		}
+
+		switch op {
+		case opEqual:
+			return v == s
+		case opNotEqual:
+			return v != s
+		case opBeginsWith:
+			return strings.HasPrefix(v, s)
+		case opEndsWith:
+			return strings.HasSuffix(v, s)
+		case opContains:
+			return strings.Contains(v, s)
+		case opLessThan:
+			return v < s
+		case opLessThanOrEqual:
+			return v <= s
+		case opGreaterThan:
+			return v > s
+		case opGreaterThanOrEqual:
+			return v >= s
+		}
+	}
+
+	return false
+}
+
+// in reports whether v is one of the values of a list, or a part of a
+// string.
+func (p *comparisonPredicate) in(v string) bool {
+	if s, ok := p.value.(string); ok {
+		if p.fold {
+			return strings.Contains(strings.ToLower(s), strings.ToLower(v))
+		}
+		return strings.Contains(s, v)
+	}
+
+	list, _ := p.value.([]interface{})
+	for _, e := range list {
+		if s, ok := e.(string); ok && (s == v || p.fold && strings.EqualFold(s, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// predicateKeys maps the key paths of smart folder predicates to the item
+// properties compared by comparisonPredicate.
+var predicateKeys = map[string]string{
+	"title":                 "title",
+	"overview.title":        "title",
+	"url":                   "url",
+	"overview.url":          "url",
+	"location":              "url",
+	"domain":                "domain",
+	"overview.domain":       "domain",
+	"category":              "category",
+	"categoryUUID":          "category",
+	"typeName":              "category",
+	"trashed":               "trashed",
+	"isTrashed":             "trashed",
+	"tags":                  "tags",
+	"overview.tags":         "tags",
+	"openContents.tags":     "tags",
+	"openContents.trashed":  "trashed",
+	"openContents.category": "category",
+}
+
+// decodePredicate decodes an NSPredicate archived with NSKeyedArchiver.
+func decodePredicate(data []byte) (predicate, error) {
+	top, err := parseBinaryPlist(data)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, _ := top.(map[string]interface{})
+	objects, _ := archive["$objects"].([]interface{})
+	root, _ := archive["$top"].(map[string]interface{})
+	if objects == nil || root == nil {
+		return nil, errors.New("not a keyed archive")
+	}
+
+	a := &keyedArchive{objects: objects}
+	return a.predicate(a.resolve(root["root"]), 0)
+}
+
+type keyedArchive struct {
+	objects []interface{}
+}
+
+// resolve follows a reference to an object of the archive. "$null" is
+// decoded to nil.
+func (a *keyedArchive) resolve(v interface{}) interface{} {
+	uid, ok := v.(plistUID)
+	if !ok {
+		return v
+	}
+	if uint64(uid) >= uint64(len(a.objects)) {
+		return nil
+	}
+	if s, ok := a.objects[uid].(string); ok && s == "$null" {
+		return nil
+	}
+	return a.objects[uid]
+}
+
+// class returns the class name of an archived object.
+func (a *keyedArchive) class(obj map[string]interface{}) string {
+	class, _ := a.resolve(obj["$class"]).(map[string]interface{})
+	name, _ := class["$classname"].(string)
+	return name
+}
+
+// value decodes a constant: strings, numbers and arrays of them.
+func (a *keyedArchive) value(v interface{}, depth int) (interface{}, error) {
+	if depth > 16 {
+		return nil, errors.New("predicate nested too deeply")
+	}
+
+	switch v := a.resolve(v).(type) {
+	case nil, bool, int64, float64, string:
+		return v, nil
+	case map[string]interface{}:
+		switch class := a.class(v); class {
+		case "NSString", "NSMutableString":
+			return a.resolve(v["NS.string"]), nil
+		case "NSArray", "NSMutableArray", "NSSet", "NSMutableSet":
+			refs, _ := v["NS.objects"].([]interface{})
+			list := make([]interface{}, len(refs))
+			for i, r := range refs {
+				var err error
+				if list[i], err = a.value(r, depth+1); err != nil {
+					return nil, err
+				}
+			}
+			return list, nil
+		default:
+			return nil, fmt.Errorf("unsupported value of class %s", class)
+		}
+	}
+	return nil, errors.New("unsupported value")
+}
+
+func (a *keyedArchive) predicate(v interface{}, depth int) (predicate, error) {
+	if depth > 16 {
+		return nil, errors.New("predicate nested too deeply")
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("predicate is not an object")
+	}
+
+	switch class := a.class(obj); class {
+	case "NSTruePredicate":
+		return constantPredicate(true), nil
+
+	case "NSFalsePredicate":
+		return constantPredicate(false), nil
+
+	case "NSCompoundPredicate":
+		kind, _ := a.resolve(obj["NSCompoundPredicateType"]).(int64)
+
+		p := &compoundPredicate{kind: int(kind)}
+		list, _ := a.resolve(obj["NSSubpredicates"]).(map[string]interface{})
+		refs, _ := list["NS.objects"].([]interface{})
+		for _, r := range refs {
+			sub, err := a.predicate(a.resolve(r), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			p.subs = append(p.subs, sub)
+		}
+		if kind < 0 || kind > 2 || (kind == 0 && len(p.subs) != 1) {
+			return nil, fmt.Errorf("unsupported compound predicate type %d", kind)
+		}
+		return p, nil
+
+	case "NSComparisonPredicate":
+		return a.comparison(obj)
+
+	default:
+		return nil, fmt.Errorf("unsupported predicate class %s", class)
+	}
+}
+
+func (a *keyedArchive) comparison(obj map[string]interface{}) (predicate, error) {
+	p := &comparisonPredicate{}
+
+	// The operator is either archived as an NSPredicateOperator or stored
+	// in the predicate itself.
+	operator, _ := a.resolve(obj["NSPredicateOperator"]).(map[string]interface{})
+	if operator == nil {
+		operator = obj
+	}
+	op, ok := a.resolve(operator["NSOperatorType"]).(int64)
+	if !ok {
+		op, ok = a.resolve(operator["NSPredicateOperatorType"]).(int64)
+	}
+	if !ok {
+		return nil, errors.New("comparison without operator")
+	}
+	p.op = int(op)
+
+	options, _ := a.resolve(operator["NSOptions"]).(int64)
+	p.fold = options&optCaseInsensitive != 0
+
+	modifier, ok := a.resolve(operator["NSModifier"]).(int64)
+	if !ok {
+		modifier, _ = a.resolve(obj["NSComparisonPredicateModifier"]).(int64)
+	}
+	p.modifier = int(modifier)
+
+	p.negate, _ = a.resolve(operator["NSNegate"]).(bool)
+
+	left, _ := a.resolve(obj["NSLeftExpression"]).(map[string]interface{})
+	right, _ := a.resolve(obj["NSRightExpression"]).(map[string]interface{})
+
+	keyPath, err := a.keyPath(left)
+	if err != nil {
+		return nil, err
+	}
+	key, found := predicateKeys[keyPath]
+	if !found {
+		return nil, fmt.Errorf("unsupported key path %q", keyPath)
+	}
+	p.key = key
+
+	p.value, err = a.constant(right)
+	if err != nil {
+		return nil, err
+	}
+
+	// Categories are compared by their code, but may be given by name.
+	if p.key == "category" {
+		switch v := p.value.(type) {
+		case string:
+			p.value = categoryCode(v)
+		case []interface{}:
+			for i, e := range v {
+				if s, ok := e.(string); ok {
+					v[i] = categoryCode(s)
+				}
+			}
+		}
+	}
+
+	switch p.op {
+	case opEqual, opNotEqual, opBeginsWith, opEndsWith, opContains, opIn,
+		opLessThan, opLessThanOrEqual, opGreaterThan, opGreaterThanOrEqual:
+	case opMatches, opLike:
+		s, ok := p.value.(string)
+		if !ok {
+			return nil, errors.New("pattern is not a string")
+		}
+		pattern := "^(?:" + s + ")$"
+		if p.op == opLike {
+			pattern = regexp.QuoteMeta(s)
+			pattern = strings.Replace(pattern, `\*`, ".*", -1)
+			pattern = strings.Replace(pattern, `\?`, ".", -1)
+			pattern = "^" + pattern + "$"
+		}
+		if p.fold {
+			pattern = "(?i)" + pattern
+		}
+		if p.re, err = regexp.Compile("(?s)" + pattern); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported comparison operator %d", p.op)
+	}
+
+	if p.modifier != modDirect && p.key != "tags" {
+		return nil, fmt.Errorf("ANY and ALL are only supported for tags")
+	}
+	if p.key == "tags" && p.modifier == modDirect && p.op != opContains {
+		return nil, fmt.Errorf("unsupported comparison of tags")
+	}
+	if p.key == "trashed" && p.op != opEqual && p.op != opNotEqual {
+		return nil, fmt.Errorf("unsupported comparison of trashed")
+	}
+
+	return p, nil
+}
+
+// categoryCode returns the code of a category given by its code, its type
+// string ("secure-note") or its name ("Secure Note").
+func categoryCode(s string) string {
+	c := FromTypeString(strings.Replace(strings.ToLower(s), " ", "-", -1))
+	if c.TypeString() == "unknown" {
+		return s
+	}
+	return string(c)
+}
+
+// keyPath returns the key path of an NSKeyPathExpression. Depending on the
+// version of Foundation it is stored directly or as the argument of a
+// valueForKey: function expression.
+func (a *keyedArchive) keyPath(expr map[string]interface{}) (string, error) {
+	if expr == nil {
+		return "", errors.New("comparison without left expression")
+	}
+
+	if s, ok := a.resolve(expr["NSKeyPath"]).(string); ok {
+		return s, nil
+	}
+
+	selector, _ := a.resolve(expr["NSSelectorName"]).(string)
+	if selector == "valueForKey:" || selector == "valueForKeyPath:" {
+		args, _ := a.resolve(expr["NSArguments"]).(map[string]interface{})
+		refs, _ := args["NS.objects"].([]interface{})
+		if len(refs) == 1 {
+			arg, _ := a.resolve(refs[0]).(map[string]interface{})
+			if s, ok := a.constantValue(arg).(string); ok {
+				return s, nil
+			}
+		}
+	}
+
+	return "", errors.New("left expression is not a key path")
+}
+
+// constant returns the value of an NSConstantValueExpression.
+func (a *keyedArchive) constant(expr map[string]interface{}) (interface{}, error) {
+	if expr == nil {
+		return nil, errors.New("comparison without right expression")
+	}
+	if t, _ := a.resolve(expr["NSExpressionType"]).(int64); t != 0 {
+		return nil, errors.New("right expression is not a constant")
+	}
+	return a.value(expr["NSConstantValue"], 0)
+}
+
+func (a *keyedArchive) constantValue(expr map[string]interface{}) interface{} {
+	v, err := a.constant(expr)
+	if err != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,179 @@
+package opvault
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+// Keyed archives, in the format written by NSKeyedArchiver, of:
+//
+//	(category == "Login" OR ANY tags ==[c] "work") AND title BEGINSWITH[c] "git" AND trashed == 0
+var andPredicate = mustDecodeBase64(
+	"YnBsaXN0MDDUAQIDBAUGBwpYJHZlcnNpb25ZJGFyY2hpdmVyVCR0b3BYJG9iamVj" +
+		"dHMSAAGGoF8QD05TS2V5ZWRBcmNoaXZlctEICVRyb290gCavECcLDA0TGhseIyYs" +
+		"Lzc4Oj1CQ0ZJUFFTVVldYGVmaGlrcXIgdHZ3e4BVJG51bGxYY2F0ZWdvcnnSDg8Q" +
+		"EVokY2xhc3NuYW1lWCRjbGFzc2VzXxATTlNLZXlQYXRoRXhwcmVzc2lvbqIQElhO" +
+		"U09iamVjdNMUFRYXGBlfEBBOU0V4cHJlc3Npb25UeXBlWU5TS2V5UGF0aFYkY2xh" +
+		"c3MQA4ABgAJVTG9naW7SDg8cHV8QGU5TQ29uc3RhbnRWYWx1ZUV4cHJlc3Npb26i" +
+		"HBLTFB8WICEiXxAPTlNDb25zdGFudFZhbHVlEACABIAF0g4PJCVfEBtOU0VxdWFs" +
+		"aXR5UHJlZGljYXRlT3BlcmF0b3KiJBLUJygpFiogICteTlNPcGVyYXRvclR5cGVa" +
+		"TlNNb2RpZmllcllOU09wdGlvbnMQBIAH0g4PLS5fEBVOU0NvbXBhcmlzb25QcmVk" +
+		"aWNhdGWiLRLUMDEyFjM0NTZfEBNOU1ByZWRpY2F0ZU9wZXJhdG9yXxAQTlNMZWZ0" +
+		"RXhwcmVzc2lvbl8QEU5TUmlnaHRFeHByZXNzaW9ugAiAA4AGgAlUdGFnc9MUHxYg" +
+		"OSKAC9IODzs8V05TQXJyYXmiOxLSPhY/QVpOUy5vYmplY3RzoUCADIANXHZhbHVl" +
+		"Rm9yS2V5OtIOD0RFXxAQTlNTZWxmRXhwcmVzc2lvbqJEEtIUFkdIEAGAENUUSktM" +
+		"FhdNTk8ZXk5TU2VsZWN0b3JOYW1lWU5TT3BlcmFuZFtOU0FyZ3VtZW50c4APgBGA" +
+		"DlR3b3Jr0xQfFiBSIoAT1CcoKRYqVEcrEALUMDEyFlZXWDaAFYASgBTSPhZaQaJb" +
+		"XIAKgBbSDg9eX18QE05TQ29tcG91bmRQcmVkaWNhdGWiXhLTYWIWVGNkXxAXTlND" +
+		"b21wb3VuZFByZWRpY2F0ZVR5cGVfEA9OU1N1YnByZWRpY2F0ZXOAF4AYVXRpdGxl" +
+		"0xQVFhdnGYAaU2dpdNMUHxYgaiKAHNZsbSkxMhZuIEdvcDZfEBdOU1ByZWRpY2F0" +
+		"ZU9wZXJhdG9yVHlwZV8QHU5TQ29tcGFyaXNvblByZWRpY2F0ZU1vZGlmaWVyEAiA" +
+		"G4AdV3RyYXNoZWTTFBUWF3MZgB/TFB8WIHUigCHUJygpFiogICvUMDEyFnh5ejaA" +
+		"I4AggCLSPhZ8QaN9fn+AGYAegCTTYWIWR4FkgCUACAARABoAJAApADIANwBJAEwA" +
+		"UQBTAH0AgwCMAJEAnAClALsAvgDHAM4A4QDrAPIA9AD2APgA/gEDAR8BIgEpATsB" +
+		"PQE/AUEBRgFkAWcBcAF/AYoBlAGWAZgBnQG1AbgBwQHXAeoB/gIAAgICBAIGAgsC" +
+		"EgIUAhkCIQIkAikCNAI2AjgCOgJHAkwCXwJiAmcCaQJrAnYChQKPApsCnQKfAqEC" +
+		"pgKtAq8CuAK6AsMCxQLHAskCzgLRAtMC1QLaAvAC8wL6AxQDJgMoAyoDMAM3AzkD" +
+		"PQNEA0YDUwNtA40DjwORA5MDmwOiA6QDqwOtA7YDvwPBA8MDxQPKA84D0APSA9QD" +
+		"2wAAAAAAAAIBAAAAAAAAAIIAAAAAAAAAAAAAAAAAAAPd")
+
+// NOT (title CONTAINS "Ünïcode" OR overview.tags CONTAINS "work")
+var notPredicate = mustDecodeBase64(
+	"YnBsaXN0MDDUAQIDBAUGBwpYJHZlcnNpb25ZJGFyY2hpdmVyVCR0b3BYJG9iamVj" +
+		"dHMSAAGGoF8QD05TS2V5ZWRBcmNoaXZlctEICVRyb290gBavEBcLDA0TGhseIyYs" +
+		"Lzc4Ojs9PkJFS05UV1UkbnVsbFV0aXRsZdIODxARWiRjbGFzc25hbWVYJGNsYXNz" +
+		"ZXNfEBNOU0tleVBhdGhFeHByZXNzaW9uohASWE5TT2JqZWN00xQVFhcYGV8QEE5T" +
+		"RXhwcmVzc2lvblR5cGVZTlNLZXlQYXRoViRjbGFzcxADgAGAAmcA3ABuAO8AYwBv" +
+		"AGQAZdIODxwdXxAZTlNDb25zdGFudFZhbHVlRXhwcmVzc2lvbqIcEtMUHxYgISJf" +
+		"EA9OU0NvbnN0YW50VmFsdWUQAIAEgAXSDg8kJV8QG05TRXF1YWxpdHlQcmVkaWNh" +
+		"dGVPcGVyYXRvcqIkEtQnKCkWKiAgK15OU09wZXJhdG9yVHlwZVpOU01vZGlmaWVy" +
+		"WU5TT3B0aW9ucxBjgAfSDg8tLl8QFU5TQ29tcGFyaXNvblByZWRpY2F0ZaItEtQw" +
+		"MTIWMzQ1Nl8QE05TUHJlZGljYXRlT3BlcmF0b3JfEBBOU0xlZnRFeHByZXNzaW9u" +
+		"XxARTlNSaWdodEV4cHJlc3Npb26ACIADgAaACV1vdmVydmlldy50YWdz0xQVFhc5" +
+		"GYALVHdvcmvTFB8WIDwigA3UJygpFiogICvUMDEyFj9AQTaAD4AMgA7SDg9DRFdO" +
+		"U0FycmF5okMS0kYWR0paTlMub2JqZWN0c6JISYAKgBCAEdIOD0xNXxATTlNDb21w" +
+		"b3VuZFByZWRpY2F0ZaJMEtNPUBZRUlNfEBdOU0NvbXBvdW5kUHJlZGljYXRlVHlw" +
+		"ZV8QD05TU3VicHJlZGljYXRlcxACgBKAE9JGFlVKoVaAFNNPUBYgWFOAFQAIABEA" +
+		"GgAkACkAMgA3AEkATABRAFMAbQBzAHkAfgCJAJIAqACrALQAuwDOANgA3wDhAOMA" +
+		"5QD0APkBFQEYAR8BMQEzATUBNwE8AVoBXQFmAXUBgAGKAYwBjgGTAasBrgG3Ac0B" +
+		"4AH0AfYB+AH6AfwCCgIRAhMCGAIfAiECKgIzAjUCNwI5Aj4CRgJJAk4CWQJcAl4C" +
+		"YAJiAmcCfQKAAocCoQKzArUCtwK5Ar4CwALCAskAAAAAAAACAQAAAAAAAABZAAAA" +
+		"AAAAAAAAAAAAAAACyw==")
+
+// category IN {"Secure Note", "005"}
+var inPredicate = mustDecodeBase64(
+	"YnBsaXN0MDDUAQIDBAUGBwpYJHZlcnNpb25ZJGFyY2hpdmVyVCR0b3BYJG9iamVj" +
+		"dHMSAAGGoF8QD05TS2V5ZWRBcmNoaXZlctEICVRyb290gA2uCwwNDhQbHB8lKC0w" +
+		"NjlVJG51bGxbU2VjdXJlIE5vdGVTMDA10g8QERJaJGNsYXNzbmFtZVgkY2xhc3Nl" +
+		"c1VOU1NldKIRE1hOU09iamVjdNIVFhcaWk5TLm9iamVjdHNWJGNsYXNzohgZgAGA" +
+		"AoADWGNhdGVnb3J50g8QHR5fEBNOU0tleVBhdGhFeHByZXNzaW9uoh0T0yAhFiIj" +
+		"JF8QEE5TRXhwcmVzc2lvblR5cGVZTlNLZXlQYXRoEAOABYAG0g8QJidfEBlOU0Nv" +
+		"bnN0YW50VmFsdWVFeHByZXNzaW9uoiYT0yApFiorLF8QD05TQ29uc3RhbnRWYWx1" +
+		"ZRAAgASACNIPEC4vXxAbTlNFcXVhbGl0eVByZWRpY2F0ZU9wZXJhdG9yoi4T1DEy" +
+		"MxY0Kio1Xk5TT3BlcmF0b3JUeXBlWk5TTW9kaWZpZXJZTlNPcHRpb25zEAqACtIP" +
+		"EDc4XxAVTlNDb21wYXJpc29uUHJlZGljYXRlojcT1Do7PBY9Pj9AXxATTlNQcmVk" +
+		"aWNhdGVPcGVyYXRvcl8QEE5TTGVmdEV4cHJlc3Npb25fEBFOU1JpZ2h0RXhwcmVz" +
+		"c2lvboALgAeACYAMAAgAEQAaACQAKQAyADcASQBMAFEAUwBiAGgAdAB4AH0AiACR" +
+		"AJcAmgCjAKgAswC6AL0AvwDBAMMAzADRAOcA6gDxAQQBDgEQARIBFAEZATUBOAE/" +
+		"AVEBUwFVAVcBXAF6AX0BhgGVAaABqgGsAa4BswHLAc4B1wHtAgACFAIWAhgCGgAA" +
+		"AAAAAAIBAAAAAAAAAEEAAAAAAAAAAAAAAAAAAAIc")
+
+// modifiedAt > 0
+var unsupportedPredicate = mustDecodeBase64(
+	"YnBsaXN0MDDUAQIDBAUGBwpYJHZlcnNpb25ZJGFyY2hpdmVyVCR0b3BYJG9iamVj" +
+		"dHMSAAGGoF8QD05TS2V5ZWRBcmNoaXZlctEICVRyb290gAqrCwwNExobHiIlKy5V" +
+		"JG51bGxabW9kaWZpZWRBdNIODxARWiRjbGFzc25hbWVYJGNsYXNzZXNfEBNOU0tl" +
+		"eVBhdGhFeHByZXNzaW9uohASWE5TT2JqZWN00xQVFhcYGV8QEE5TRXhwcmVzc2lv" +
+		"blR5cGVZTlNLZXlQYXRoViRjbGFzcxADgAGAAhAA0g4PHB1fEBlOU0NvbnN0YW50" +
+		"VmFsdWVFeHByZXNzaW9uohwS0xQfFhogIV8QD05TQ29uc3RhbnRWYWx1ZYAEgAXS" +
+		"Dg8jJF8QG05TRXF1YWxpdHlQcmVkaWNhdGVPcGVyYXRvcqIjEtQmJygWKRoaKl5O" +
+		"U09wZXJhdG9yVHlwZVpOU01vZGlmaWVyWU5TT3B0aW9ucxACgAfSDg8sLV8QFU5T" +
+		"Q29tcGFyaXNvblByZWRpY2F0ZaIsEtQvMDEWMjM0NV8QE05TUHJlZGljYXRlT3Bl" +
+		"cmF0b3JfEBBOU0xlZnRFeHByZXNzaW9uXxARTlNSaWdodEV4cHJlc3Npb26ACIAD" +
+		"gAaACQAIABEAGgAkACkAMgA3AEkATABRAFMAXwBlAHAAdQCAAIkAnwCiAKsAsgDF" +
+		"AM8A1gDYANoA3ADeAOMA/wECAQkBGwEdAR8BJAFCAUUBTgFdAWgBcgF0AXYBewGT" +
+		"AZYBnwG1AcgB3AHeAeAB4gAAAAAAAAIBAAAAAAAAADYAAAAAAAAAAAAAAAAAAAHk")
+
+func mustDecodeBase64(s string) []byte {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestPredicate(t *testing.T) {
+	items := []*Item{
+		{UUID: "A", Category: LoginItem, Data: &ItemData{Title: "GitHub"}},
+		{UUID: "B", Category: SecureNoteItem, Data: &ItemData{Title: "git notes", Tags: []string{"Work"}}},
+		{UUID: "C", Category: SecureNoteItem, Data: &ItemData{Title: "GitLab"}},
+		{UUID: "D", Category: LoginItem, Data: &ItemData{Title: "GitLab"}, Trashed: true},
+		{UUID: "E", Category: PasswordItem, Data: &ItemData{Title: "Ünïcode test"}},
+		{UUID: "F", Category: LoginItem, Data: &ItemData{Title: "Mail", Tags: []string{"home", "work"}}},
+	}
+
+	tests := []struct {
+		name      string
+		predicate []byte
+		matches   string
+	}{
+		{"and", andPredicate, "AB"},
+		{"not", notPredicate, "ABCD"},
+		{"in", inPredicate, "BCE"},
+	}
+
+	for _, test := range tests {
+		p, err := decodePredicate(test.predicate)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		var matches string
+		for _, item := range items {
+			if p.match(item) {
+				matches += item.UUID
+			}
+		}
+		if matches != test.matches {
+			t.Errorf("%s matches %q, want %q", test.name, matches, test.matches)
+		}
+	}
+
+	if _, err := decodePredicate(unsupportedPredicate); err == nil {
+		t.Error("unsupported predicate decoded")
+	}
+	if _, err := decodePredicate(andPredicate[:len(andPredicate)-40]); err == nil {
+		t.Error("truncated predicate decoded")
+	}
+}
+
+func TestSearchSmartFolder(t *testing.T) {
+	v := &Vault{folders: Folders{
+		"F1": &Folder{UUID: "F1", Smart: true, Data: &FolderData{Title: "Git", Predicate: andPredicate}},
+		"F2": &Folder{UUID: "F2", Smart: true, Data: &FolderData{Title: "Recent", Predicate: unsupportedPredicate}},
+	}}
+	v.bands[0] = Band{
+		"0A": &Item{UUID: "0A", Category: LoginItem, Data: &ItemData{Title: "GitHub"}},
+		"0B": &Item{UUID: "0B", Category: LoginItem, Data: &ItemData{Title: "Mail"}},
+	}
+
+	q, err := ParseQuery("folder:Git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := v.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].UUID != "0A" {
+		t.Errorf("Search(folder:Git) = %v, want 0A", items)
+	}
+
+	q, err = ParseQuery("folder:Recent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Search(q); err == nil {
+		t.Error("Search(folder:Recent) did not fail")
+	}
+}
@@ -5,21 +5,22 @@ import (
 	"crypto/sha512"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"golang.org/x/crypto/pbkdf2"
 )
 
 type Profile struct {
-	UUID          string
-	UpdatedAt     int64
-	CreatedAt     int64
-	LastUpdatedBy string
-	ProfileName   string
-	PasswordHint  string
-	Iterations    int
-	Salt          []byte
-	OverviewKey   []byte
-	MasterKey     []byte
+	UUID          string `json:"uuid"`
+	UpdatedAt     int64  `json:"updatedAt"`
+	CreatedAt     int64  `json:"createdAt"`
+	LastUpdatedBy string `json:"lastUpdatedBy"`
+	ProfileName   string `json:"profileName"`
+	PasswordHint  string `json:"passwordHint"`
+	Iterations    int    `json:"iterations"`
+	Salt          []byte `json:"salt"`
+	OverviewKey   []byte `json:"overviewKey"`
+	MasterKey     []byte `json:"masterKey"`
 
 	masterEncKey   []byte
 	masterMacKey   []byte
@@ -27,6 +28,16 @@ type Profile struct {
 	overviewMacKey []byte
 }
 
+// Keys are the keys of a profile which are unlocked by the master
+// password. They can be used to open the profile again without repeating
+// the (slow) key derivation.
+type Keys struct {
+	MasterEncKey   []byte `json:"masterEncKey"`
+	MasterMacKey   []byte `json:"masterMacKey"`
+	OverviewEncKey []byte `json:"overviewEncKey"`
+	OverviewMacKey []byte `json:"overviewMacKey"`
+}
+
 func parseProfile(data []byte) (*Profile, error) {
 	var (
 		idx     int
@@ -53,14 +64,113 @@ func parseProfile(data []byte) (*Profile, error) {
 	return profile, nil
 }
 
-func (p *Profile) setMasterPassword(pwd string) error {
-
+// newProfile generates a new profile with random master and overview keys
+// which are protected by the master password.
+func newProfile(name, pwd, hint string, iterations int) (*Profile, error) {
 	var (
-		dk            = pbkdf2.Key([]byte(pwd), p.Salt, p.Iterations, 64, sha512.New)
-		derivedEncKey = dk[:32]
-		derivedMacKey = dk[32:]
+		now = time.Now().Unix()
+		p   = &Profile{
+			UpdatedAt:     now,
+			CreatedAt:     now,
+			LastUpdatedBy: "1pwd",
+			ProfileName:   name,
+			PasswordHint:  hint,
+			Iterations:    iterations,
+		}
+		err error
 	)
 
+	p.UUID, err = newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	p.Salt, err = randomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := randomBytes(256)
+	if err != nil {
+		return nil, err
+	}
+
+	overviewKey, err := randomBytes(256)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedEncKey, derivedMacKey := p.deriveKeys(pwd)
+
+	p.MasterKey, err = encrypt(masterKey, derivedEncKey, derivedMacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p.OverviewKey, err = encrypt(overviewKey, derivedEncKey, derivedMacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p.setKeys(masterKey, overviewKey)
+
+	return p, nil
+}
+
+// withMasterPassword returns a copy of the profile with the master and
+// overview keys protected by a new master password, using a fresh salt.
+func (p *Profile) withMasterPassword(old, pwd string, iterations int) (*Profile, error) {
+	derivedEncKey, derivedMacKey := p.deriveKeys(old)
+
+	masterKey, err := decrypt(nil, p.MasterKey, derivedEncKey, derivedMacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	overviewKey, err := decrypt(nil, p.OverviewKey, derivedEncKey, derivedMacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := *p
+	n.UpdatedAt = time.Now().Unix()
+	n.LastUpdatedBy = "1pwd"
+	if iterations > 0 {
+		n.Iterations = iterations
+	}
+
+	n.Salt, err = randomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedEncKey, derivedMacKey = n.deriveKeys(pwd)
+
+	n.MasterKey, err = encrypt(masterKey, derivedEncKey, derivedMacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n.OverviewKey, err = encrypt(overviewKey, derivedEncKey, derivedMacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (p *Profile) write(path string) error {
+	return writeJSONP(path, "var profile=", ";", p)
+}
+
+func (p *Profile) deriveKeys(pwd string) (encKey, macKey []byte) {
+	dk := pbkdf2.Key([]byte(pwd), p.Salt, p.Iterations, 64, sha512.New)
+	return dk[:32], dk[32:]
+}
+
+func (p *Profile) setMasterPassword(pwd string) error {
+	derivedEncKey, derivedMacKey := p.deriveKeys(pwd)
+
 	masterKey, err := decrypt(nil, p.MasterKey, derivedEncKey, derivedMacKey)
 	if err != nil {
 		return err
@@ -71,6 +181,35 @@ func (p *Profile) setMasterPassword(pwd string) error {
 		return err
 	}
 
+	p.setKeys(masterKey, overviewKey)
+
+	return nil
+}
+
+func (p *Profile) keys() *Keys {
+	return &Keys{
+		MasterEncKey:   p.masterEncKey,
+		MasterMacKey:   p.masterMacKey,
+		OverviewEncKey: p.overviewEncKey,
+		OverviewMacKey: p.overviewMacKey,
+	}
+}
+
+func (p *Profile) setDerivedKeys(k *Keys) error {
+	if len(k.MasterEncKey) != 32 || len(k.MasterMacKey) != 32 ||
+		len(k.OverviewEncKey) != 32 || len(k.OverviewMacKey) != 32 {
+		return errors.New("invalid profile keys")
+	}
+
+	p.masterEncKey = k.MasterEncKey
+	p.masterMacKey = k.MasterMacKey
+	p.overviewEncKey = k.OverviewEncKey
+	p.overviewMacKey = k.OverviewMacKey
+
+	return nil
+}
+
+func (p *Profile) setKeys(masterKey, overviewKey []byte) {
 	mac := sha512.New()
 	mac.Write(masterKey)
 	macData := mac.Sum(nil)
@@ -82,6 +221,4 @@ func (p *Profile) setMasterPassword(pwd string) error {
 	macData = mac.Sum(nil)
 	p.overviewEncKey = macData[:32]
 	p.overviewMacKey = macData[32:]
-
-	return nil
 }
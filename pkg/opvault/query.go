@@ -0,0 +1,257 @@
+package opvault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A Query selects items by their (decrypted) overview data. It is made of
+// space separated terms which all have to match:
+//
+//	github              title or domain contains "github"
+//	title:github        title contains "github"
+//	url:/admin          one of the URLs contains "/admin"
+//	domain:example.com  domain is example.com or one of its sub domains
+//	type:login          item is of the given type
+//	folder:Infra        item is in the folder (or one of its sub folders)
+//	                    or matches the predicate of the smart folder
+//	tag:prod            item has the tag
+//	uuid:0A1B...        item has the UUID
+//	trashed, fave       item is trashed or a favorite
+//
+// Values may be quoted ("my bank") and may contain * and ? wildcards, in
+// which case they have to match the whole value (domain:*.corp). An empty
+// value matches items without one (tag: finds untagged items). A leading -
+// negates a term. Trashed items only match when the query mentions
+// trashed and tombstones only match type:tombstone.
+type Query struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	key    string
+	value  string
+	negate bool
+	match  *regexp.Regexp
+}
+
+var queryKeys = map[string]bool{
+	"title":   true,
+	"url":     true,
+	"domain":  true,
+	"type":    true,
+	"folder":  true,
+	"tag":     true,
+	"uuid":    true,
+	"trashed": false,
+	"fave":    false,
+}
+
+// ParseQuery parses a query.
+func ParseQuery(s string) (*Query, error) {
+	words, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, word := range words {
+		var term queryTerm
+
+		if strings.HasPrefix(word, "-") && len(word) > 1 {
+			term.negate = true
+			word = word[1:]
+		}
+
+		if i := strings.IndexByte(word, ':'); i > 0 {
+			term.key, term.value = strings.ToLower(word[:i]), word[i+1:]
+			if !queryKeys[term.key] {
+				return nil, fmt.Errorf("unknown query key %q", term.key)
+			}
+		} else if hasValue, found := queryKeys[strings.ToLower(word)]; found && !hasValue {
+			term.key = strings.ToLower(word)
+		} else if word == "" {
+			// An empty term ("") matches everything.
+			continue
+		} else {
+			term.value = word
+		}
+
+		if term.key == "type" && !strings.ContainsAny(term.value, "*?") && FromTypeString(term.value).TypeString() == "unknown" {
+			return nil, fmt.Errorf("unknown item type %q", term.value)
+		}
+
+		term.match = compileTerm(term.key, term.value)
+		q.terms = append(q.terms, term)
+	}
+
+	return q, nil
+}
+
+// splitQuery splits s into words, honouring double quotes.
+func splitQuery(s string) ([]string, error) {
+	var (
+		words  []string
+		word   []rune
+		inWord bool
+		quoted bool
+	)
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, string(word))
+			}
+			word, inWord = word[:0], false
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote in query")
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+
+	return words, nil
+}
+
+func compileTerm(key, value string) *regexp.Regexp {
+	if value == "" {
+		return nil
+	}
+
+	if strings.ContainsAny(value, "*?") {
+		pattern := regexp.QuoteMeta(value)
+		pattern = strings.Replace(pattern, `\*`, ".*", -1)
+		pattern = strings.Replace(pattern, `\?`, ".", -1)
+		return regexp.MustCompile("(?is)^" + pattern + "$")
+	}
+
+	pattern := regexp.QuoteMeta(value)
+	switch key {
+	case "", "title", "url":
+		return regexp.MustCompile("(?i)" + pattern)
+	case "domain":
+		return regexp.MustCompile(`(?i)^(.*\.)?` + pattern + "$")
+	default:
+		return regexp.MustCompile("(?i)^" + pattern + "$")
+	}
+}
+
+// Search returns all the items (sorted like All) matching the query.
+func (v *Vault) Search(q *Query) ([]*Item, error) {
+	var (
+		folders    = make(map[int]*Folder)
+		trashed    bool
+		tombstones bool
+		results    []*Item
+	)
+
+	for i, term := range q.terms {
+		switch term.key {
+		case "trashed":
+			trashed = true
+		case "type":
+			tombstones = tombstones || FromTypeString(term.value) == TombstoneItem
+		case "folder":
+			if term.value == "" {
+				continue
+			}
+			folder, err := v.Folder(term.value)
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("folder %q not found", term.value)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if folder.Smart {
+				if _, err := folder.smartPredicate(); err != nil {
+					return nil, fmt.Errorf("cannot search smart folder %q: %s", term.value, err)
+				}
+			}
+			folders[i] = folder
+		}
+	}
+
+	for _, item := range v.All() {
+		if item.Trashed && !trashed {
+			continue
+		}
+		if item.Category == TombstoneItem && !tombstones {
+			continue
+		}
+
+		matched := true
+		for i, term := range q.terms {
+			if term.matchItem(v, item, folders[i]) == term.negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, item)
+		}
+	}
+
+	return results, nil
+}
+
+func (t *queryTerm) matchItem(v *Vault, item *Item, folder *Folder) bool {
+	switch t.key {
+	case "":
+		return t.match.MatchString(item.Data.Title) || t.match.MatchString(item.Data.Domain)
+	case "title":
+		return t.matchString(item.Data.Title)
+	case "url":
+		for _, u := range item.URLs() {
+			if t.matchString(u) {
+				return true
+			}
+		}
+		return t.match == nil && len(item.URLs()) == 0
+	case "domain":
+		return t.matchString(item.Data.Domain)
+	case "type":
+		return t.matchString(item.Category.TypeString())
+	case "folder":
+		if folder == nil {
+			return v.FolderOf(item) == nil
+		}
+		in, _ := v.InFolder(item, folder)
+		return in
+	case "tag":
+		for _, tag := range item.Data.Tags {
+			if t.matchString(tag) {
+				return true
+			}
+		}
+		return t.match == nil && len(item.Data.Tags) == 0
+	case "uuid":
+		return t.matchString(item.UUID)
+	case "trashed":
+		return item.Trashed
+	case "fave":
+		return item.Fave > 0
+	}
+	return false
+}
+
+// matchString matches s against the value of the term. An empty value only
+// matches an empty string.
+func (t *queryTerm) matchString(s string) bool {
+	if t.match == nil {
+		return s == ""
+	}
+	return t.match.MatchString(s)
+}
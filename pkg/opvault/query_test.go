@@ -0,0 +1,36 @@
+package opvault
+
+import "testing"
+
+func TestSearchEmptyTerm(t *testing.T) {
+	v := &Vault{}
+	v.bands[0] = Band{
+		"0A": &Item{UUID: "0A", Category: LoginItem, Data: &ItemData{Title: "GitHub", Domain: "github.com"}},
+		"0B": &Item{UUID: "0B", Category: LoginItem, Data: &ItemData{Title: "Mail", Domain: "mail.example.com"}},
+	}
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{`""`, 2},
+		{`"" ""`, 2},
+		{`"" git`, 1},
+		{`title:""`, 0},
+	}
+
+	for _, test := range tests {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%s): %s", test.query, err)
+		}
+
+		items, err := v.Search(q)
+		if err != nil {
+			t.Fatalf("Search(%s): %s", test.query, err)
+		}
+		if len(items) != test.count {
+			t.Errorf("Search(%s) = %d items, want %d", test.query, len(items), test.count)
+		}
+	}
+}
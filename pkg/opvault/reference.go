@@ -0,0 +1,116 @@
+package opvault
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const referencePrefix = "opvault://"
+
+// Reference points to a field of an item, written as
+// opvault://ITEM/FIELD where ITEM is the UUID or the title of the item.
+// Slashes in the item title can be escaped as %2F.
+type Reference struct {
+	Item  string
+	Field string
+}
+
+// IsReference reports whether s looks like a secret reference.
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, referencePrefix)
+}
+
+func ParseReference(s string) (Reference, error) {
+	if !IsReference(s) {
+		return Reference{}, fmt.Errorf("invalid reference %q", s)
+	}
+
+	rest := strings.TrimPrefix(s, referencePrefix)
+	idx := strings.LastIndexByte(rest, '/')
+	if idx <= 0 || idx == len(rest)-1 {
+		return Reference{}, fmt.Errorf("invalid reference %q", s)
+	}
+
+	item, err := url.PathUnescape(rest[:idx])
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid reference %q: %s", s, err)
+	}
+
+	field, err := url.PathUnescape(rest[idx+1:])
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid reference %q: %s", s, err)
+	}
+
+	return Reference{Item: item, Field: field}, nil
+}
+
+func (r Reference) String() string {
+	return referencePrefix + strings.Replace(r.Item, "/", "%2F", -1) + "/" + r.Field
+}
+
+// Find looks up an item by its UUID or by its title. Trashed items and
+// tombstones are never found by title.
+func (v *Vault) Find(name string) (*Item, error) {
+	if item, err := v.Get(name); err == nil {
+		return item, nil
+	}
+
+	var found []*Item
+	for _, band := range v.bands {
+		for _, item := range band {
+			if item.Trashed || item.Category == TombstoneItem {
+				continue
+			}
+			if item.Data.Title == name {
+				found = append(found, item)
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		for _, band := range v.bands {
+			for _, item := range band {
+				if item.Trashed || item.Category == TombstoneItem {
+					continue
+				}
+				if strings.EqualFold(item.Data.Title, name) {
+					found = append(found, item)
+				}
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, os.ErrNotExist
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("%q matches %d items", name, len(found))
+	}
+}
+
+// Resolve returns the (raw) value of the field the reference points to.
+func (v *Vault) Resolve(ref Reference) (string, error) {
+	item, err := v.Find(ref.Item)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s: item not found", ref)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", ref, err)
+	}
+
+	err = item.Decrypt(v)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", ref, err)
+	}
+
+	value, found := item.Extract(ref.Field)
+	if !found {
+		return "", fmt.Errorf("%s: field not found", ref)
+	}
+
+	return value, nil
+}
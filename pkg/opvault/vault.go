@@ -1,6 +1,7 @@
 package opvault
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,22 +9,60 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"time"
 )
 
 type Vault struct {
+	dir     string
 	profile *Profile
 	folders Folders
 	bands   [16]Band
+	dirty   [16]bool
 }
 
+// OpenOptions control how a vault is opened. The zero value (or nil)
+// selects the defaults.
+type OpenOptions struct {
+	// Profile is the name of the profile to open ("default" when empty).
+	Profile string
+
+	// Strict makes opening fail when the HMAC of an item does not match.
+	// Otherwise items with an invalid HMAC are loaded as usual and can be
+	// found with Item.ValidHMAC.
+	Strict bool
+
+	// Keys (as returned by Vault.Keys) unlock the profile instead of the
+	// master password.
+	Keys *Keys
+}
+
+// Open opens the default profile of the vault at path.
 func Open(path, master string) (*Vault, error) {
+	return OpenWithOptions(path, master, nil)
+}
+
+// OpenProfile opens the named profile of the vault at path.
+func OpenProfile(path, profileName, master string) (*Vault, error) {
+	return OpenWithOptions(path, master, &OpenOptions{Profile: profileName})
+}
+
+func OpenWithOptions(path, master string, opts *OpenOptions) (*Vault, error) {
+	if opts == nil {
+		opts = &OpenOptions{}
+	}
+
+	profileName := opts.Profile
+	if profileName == "" {
+		profileName = "default"
+	}
+
 	var (
-		vault = &Vault{}
+		vault = &Vault{dir: filepath.Join(path, profileName)}
 		data  []byte
 		err   error
 	)
 
-	data, err = ioutil.ReadFile(filepath.Join(path, "default", "profile.js"))
+	data, err = ioutil.ReadFile(filepath.Join(vault.dir, "profile.js"))
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +72,7 @@ func Open(path, master string) (*Vault, error) {
 		return nil, err
 	}
 
-	data, err = ioutil.ReadFile(filepath.Join(path, "default", "folders.js"))
+	data, err = ioutil.ReadFile(filepath.Join(vault.dir, "folders.js"))
 	if err == nil {
 		vault.folders, err = parseFolders(data)
 		if err != nil {
@@ -42,7 +81,7 @@ func Open(path, master string) (*Vault, error) {
 	}
 
 	for i := '0'; i <= '9'; i++ {
-		data, err = ioutil.ReadFile(filepath.Join(path, "default", fmt.Sprintf("band_%c.js", i)))
+		data, err = ioutil.ReadFile(filepath.Join(vault.dir, fmt.Sprintf("band_%c.js", i)))
 		if os.IsNotExist(err) {
 			continue
 		}
@@ -59,7 +98,7 @@ func Open(path, master string) (*Vault, error) {
 	}
 
 	for i := 'A'; i <= 'F'; i++ {
-		data, err = ioutil.ReadFile(filepath.Join(path, "default", fmt.Sprintf("band_%c.js", i)))
+		data, err = ioutil.ReadFile(filepath.Join(vault.dir, fmt.Sprintf("band_%c.js", i)))
 		if os.IsNotExist(err) {
 			continue
 		}
@@ -75,7 +114,16 @@ func Open(path, master string) (*Vault, error) {
 		vault.bands[(i-'A')+10] = band
 	}
 
-	err = vault.profile.setMasterPassword(master)
+	if opts.Keys != nil {
+		err = vault.profile.setDerivedKeys(opts.Keys)
+	} else {
+		err = vault.profile.setMasterPassword(master)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = vault.verifyHMAC(opts.Strict)
 	if err != nil {
 		return nil, err
 	}
@@ -85,21 +133,90 @@ func Open(path, master string) (*Vault, error) {
 		return nil, err
 	}
 
+	err = vault.loadAttachments()
+	if err != nil {
+		return nil, err
+	}
+
+	return vault, nil
+}
+
+// CreateOptions control the creation of a new vault. The zero value (or
+// nil) selects the defaults.
+type CreateOptions struct {
+	Iterations   int
+	PasswordHint string
+}
+
+const DefaultIterations = 100000
+
+// Create makes a new, empty vault at path protected by the master password
+// and returns it opened.
+func Create(path, master string, opts *CreateOptions) (*Vault, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+
+	iterations := opts.Iterations
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+
+	vault := &Vault{dir: filepath.Join(path, "default")}
+
+	err := os.MkdirAll(path, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Mkdir(vault.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	vault.profile, err = newProfile("default", master, opts.PasswordHint, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	err = vault.profile.write(filepath.Join(vault.dir, "profile.js"))
+	if err != nil {
+		return nil, err
+	}
+
+	vault.folders = Folders{}
+	err = vault.folders.write(filepath.Join(vault.dir, "folders.js"))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vault.bands {
+		vault.bands[i] = Band{}
+		vault.dirty[i] = true
+	}
+
+	err = vault.Save()
+	if err != nil {
+		return nil, err
+	}
+
 	return vault, nil
 }
 
+// Keys returns the unlocked keys of the vault profile.
+func (v *Vault) Keys() *Keys {
+	return v.profile.keys()
+}
+
 func (v *Vault) Get(itemID string) (*Item, error) {
 	if itemID == "" {
 		return nil, os.ErrNotExist
 	}
 
-	bandID, err := strconv.ParseInt(itemID[:1], 16, 8)
+	bandID, err := bandFor(itemID)
 	if err != nil {
 		return nil, err
 	}
-	if bandID < 0 || bandID >= 16 {
-		return nil, os.ErrNotExist
-	}
 
 	band := v.bands[bandID]
 	if band == nil {
@@ -114,6 +231,184 @@ func (v *Vault) Get(itemID string) (*Item, error) {
 	return item, nil
 }
 
+// Add encrypts a new item and stores it in its band. The change is only
+// written to disk by Save.
+func (v *Vault) Add(item *Item) error {
+	if item.UUID == "" {
+		return errors.New("item has no uuid")
+	}
+
+	bandID, err := bandFor(item.UUID)
+	if err != nil {
+		return err
+	}
+
+	if v.bands[bandID][item.UUID] != nil {
+		return os.ErrExist
+	}
+
+	err = item.encrypt(v.profile)
+	if err != nil {
+		return err
+	}
+
+	if v.bands[bandID] == nil {
+		v.bands[bandID] = Band{}
+	}
+	v.bands[bandID][item.UUID] = item
+	v.dirty[bandID] = true
+
+	return nil
+}
+
+// Update re-encrypts an existing item after it was changed and bumps its
+// modification time. The change is only written to disk by Save.
+func (v *Vault) Update(item *Item) error {
+	bandID, err := bandFor(item.UUID)
+	if err != nil {
+		return err
+	}
+
+	if v.bands[bandID][item.UUID] != item {
+		return os.ErrNotExist
+	}
+
+	if item.Category == TombstoneItem {
+		return errors.New("cannot update a tombstone")
+	}
+
+	now := time.Now().Unix()
+	item.Updated = now
+	item.Tx = now
+
+	err = item.encrypt(v.profile)
+	if err != nil {
+		return err
+	}
+
+	v.dirty[bandID] = true
+
+	return nil
+}
+
+// Trash moves an item to the trash.
+func (v *Vault) Trash(item *Item) error {
+	return v.setTrashed(item, true)
+}
+
+// Restore moves an item out of the trash.
+func (v *Vault) Restore(item *Item) error {
+	return v.setTrashed(item, false)
+}
+
+// setTrashed updates the trashed flag of the item. The flag is left as it
+// was when the item cannot be updated.
+func (v *Vault) setTrashed(item *Item, trashed bool) error {
+	prev := item.Trashed
+	item.Trashed = trashed
+
+	err := v.Update(item)
+	if err != nil {
+		item.Trashed = prev
+	}
+	return err
+}
+
+// Purge permanently deletes an item by replacing it with a tombstone. All
+// the encrypted data of the item is dropped; only its UUID and timestamps
+// remain so other clients learn about the deletion when syncing. Its
+// attachments are deleted by Save.
+func (v *Vault) Purge(item *Item) error {
+	bandID, err := bandFor(item.UUID)
+	if err != nil {
+		return err
+	}
+
+	if v.bands[bandID][item.UUID] != item {
+		return os.ErrNotExist
+	}
+
+	now := time.Now().Unix()
+	item.Category = TombstoneItem
+	item.Trashed = false
+	item.Fave = 0
+	item.Folder = ""
+	item.O = nil
+	item.K = nil
+	item.D = nil
+	item.Updated = now
+	item.Tx = now
+	item.Data = &ItemData{UUID: item.UUID, Category: item.Category}
+	item.overview = nil
+	item.details = nil
+	item.raw = nil
+
+	item.HMAC, err = item.computeHMAC(v.profile)
+	if err != nil {
+		return err
+	}
+
+	v.dirty[bandID] = true
+
+	return nil
+}
+
+// ChangeMasterPassword protects the vault with a new master password. The
+// master and overview keys are re-wrapped under a key derived with a new
+// salt, so none of the items need to be re-encrypted. When iterations is 0
+// the current iteration count is kept.
+func (v *Vault) ChangeMasterPassword(old, pwd string, iterations int) error {
+	profile, err := v.profile.withMasterPassword(old, pwd, iterations)
+	if err != nil {
+		return err
+	}
+
+	err = profile.write(filepath.Join(v.dir, "profile.js"))
+	if err != nil {
+		return err
+	}
+
+	v.profile = profile
+	return nil
+}
+
+// Save writes all the bands that were changed since the vault was opened
+// (or last saved).
+func (v *Vault) Save() error {
+	for i, band := range v.bands {
+		if !v.dirty[i] {
+			continue
+		}
+
+		if band == nil {
+			band = Band{}
+		}
+
+		err := band.write(filepath.Join(v.dir, fmt.Sprintf("band_%X.js", i)))
+		if err != nil {
+			return err
+		}
+
+		// The attachments of purged items can no longer be read.
+		for _, item := range band {
+			if item.Category != TombstoneItem {
+				continue
+			}
+			for _, a := range item.attachments {
+				err := os.Remove(a.path)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			item.attachments = nil
+		}
+
+		v.dirty[i] = false
+	}
+
+	return nil
+}
+
 func (v *Vault) All() []*Item {
 	var results = make([]*Item, 0, 4096)
 
@@ -128,6 +423,83 @@ func (v *Vault) All() []*Item {
 	return results
 }
 
+// Folders returns all the folders (including smart folders) sorted by
+// their path.
+func (v *Vault) Folders() []*Folder {
+	var results = make([]*Folder, 0, len(v.folders))
+
+	for _, folder := range v.folders {
+		results = append(results, folder)
+	}
+
+	sort.Sort(foldersByPath(results))
+
+	return results
+}
+
+// Folder finds a folder by its UUID, its path or its title.
+func (v *Vault) Folder(name string) (*Folder, error) {
+	if folder := v.folders[name]; folder != nil {
+		return folder, nil
+	}
+
+	for _, folder := range v.folders {
+		if folder.Path() == name {
+			return folder, nil
+		}
+	}
+
+	var found *Folder
+	for _, folder := range v.folders {
+		if folder.Data.Title == name {
+			if found != nil {
+				return nil, fmt.Errorf("folder %q is ambiguous", name)
+			}
+			found = folder
+		}
+	}
+	if found == nil {
+		return nil, os.ErrNotExist
+	}
+
+	return found, nil
+}
+
+// FolderOf returns the folder the item is stored in (or nil).
+func (v *Vault) FolderOf(item *Item) *Folder {
+	return v.folders[item.Folder]
+}
+
+// InFolder reports whether the item is in the folder or one of its sub
+// folders. For a smart folder its predicate is evaluated instead, which
+// fails when it uses conditions other than the title, URL, category, tags
+// or trashed state of the item.
+func (v *Vault) InFolder(item *Item, folder *Folder) (bool, error) {
+	if !folder.Smart {
+		return folder.Contains(v.FolderOf(item)), nil
+	}
+
+	p, err := folder.smartPredicate()
+	if err != nil {
+		return false, err
+	}
+	return p.match(item), nil
+}
+
+// verifyHMAC checks the HMAC of every item. When strict is set the first
+// invalid one is an error, otherwise the items are marked.
+func (v *Vault) verifyHMAC(strict bool) error {
+	for _, band := range v.bands {
+		for _, item := range band {
+			item.invalidHMAC = !item.verifyHMAC(v.profile)
+			if item.invalidHMAC && strict {
+				return fmt.Errorf("item %s: %s", item.UUID, ErrInvalidHMAC)
+			}
+		}
+	}
+	return nil
+}
+
 func (v *Vault) decryptOverView() error {
 	for _, band := range v.bands {
 		err := band.decryptOverView(v.profile)
@@ -135,9 +507,48 @@ func (v *Vault) decryptOverView() error {
 			return err
 		}
 	}
+
+	err := v.folders.decryptOverView(v.profile)
+	if err != nil {
+		return err
+	}
+	v.folders.buildTree()
+
 	return nil
 }
 
+func bandFor(itemID string) (int, error) {
+	if itemID == "" {
+		return 0, os.ErrNotExist
+	}
+
+	bandID, err := strconv.ParseInt(itemID[:1], 16, 8)
+	if err != nil {
+		return 0, err
+	}
+	if bandID < 0 || bandID >= 16 {
+		return 0, os.ErrNotExist
+	}
+
+	return int(bandID), nil
+}
+
+// Profiles returns the names of all the profiles in the vault at path.
+func Profiles(path string) ([]string, error) {
+	entries, err := filepath.Glob(filepath.Join(path, "*", "profile.js"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, filepath.Base(filepath.Dir(entry)))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
 func LookupVaults() ([]string, error) {
 	var home string
 
@@ -0,0 +1,191 @@
+package opvault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func createVault(t *testing.T) (*Vault, string) {
+	path := filepath.Join(t.TempDir(), "test.opvault")
+
+	v, err := Create(path, "secret", &CreateOptions{Iterations: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v, path
+}
+
+func addLogin(t *testing.T, v *Vault, title, username, password string) *Item {
+	item, err := NewItem(LoginItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Set("title", title)
+	item.Set("username", username)
+	item.Set("password", password)
+
+	err = v.Add(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return item
+}
+
+func openVault(t *testing.T, path string) *Vault {
+	v, err := OpenWithOptions(path, "secret", &OpenOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestAddSaveOpen(t *testing.T) {
+	v, path := createVault(t)
+	id := addLogin(t, v, "Example", "alice", "hunter2").UUID
+
+	if _, err := OpenWithOptions(path, "wrong", nil); err == nil {
+		t.Error("Open with the wrong master password succeeded")
+	}
+
+	v = openVault(t, path)
+	item, err := v.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !item.ValidHMAC() {
+		t.Error("added item has an invalid HMAC")
+	}
+	if item.Data.Title != "Example" {
+		t.Errorf("title = %q, want %q", item.Data.Title, "Example")
+	}
+
+	err = item.Decrypt(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{"username": "alice", "password": "hunter2"} {
+		if got, _ := item.Extract(field); got != want {
+			t.Errorf("%s = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestSaveKeepsUnknownProperties(t *testing.T) {
+	v, path := createVault(t)
+	item := addLogin(t, v, "Example", "alice", "hunter2")
+
+	// add a property unknown to Item, as newer apps may, and sign it
+	bandID, err := bandFor(item.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bandPath := filepath.Join(path, "default", fmt.Sprintf("band_%X.js", bandID))
+
+	items := readBand(t, bandPath)
+	props := items[item.UUID]
+	props["newkey"] = "keep"
+	props["hmac"] = itemHMAC(props, v.profile.overviewMacKey)
+	err = writeJSONP(bandPath, "ld(", ");", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v = openVault(t, path)
+	item, err = v.Get(item.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = item.Decrypt(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Set("title", "Changed")
+	err = v.Update(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v = openVault(t, path)
+	item, err = v.Get(item.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data.Title != "Changed" {
+		t.Errorf("title = %q, want %q", item.Data.Title, "Changed")
+	}
+	if got := readBand(t, bandPath)[item.UUID]["newkey"]; got != "keep" {
+		t.Errorf("newkey = %v, want %q", got, "keep")
+	}
+
+	// a change without the keys of the vault is detected
+	items = readBand(t, bandPath)
+	items[item.UUID]["newkey"] = "changed"
+	err = writeJSONP(bandPath, "ld(", ");", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenWithOptions(path, "secret", &OpenOptions{Strict: true}); err == nil {
+		t.Error("Open of a changed item succeeded")
+	}
+	v, err = OpenWithOptions(path, "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := v.Get(item.UUID); item == nil || item.ValidHMAC() {
+		t.Error("changed item has a valid HMAC")
+	}
+}
+
+func TestTrashFailure(t *testing.T) {
+	v, _ := createVault(t)
+
+	item, err := NewItem(LoginItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Trash(item); err == nil {
+		t.Error("Trash of an item not in the vault succeeded")
+	}
+	if item.Trashed {
+		t.Error("item was trashed although Trash failed")
+	}
+
+	item.Trashed = true
+	if err := v.Restore(item); err == nil {
+		t.Error("Restore of an item not in the vault succeeded")
+	}
+	if !item.Trashed {
+		t.Error("item was restored although Restore failed")
+	}
+}
+
+func readBand(t *testing.T, path string) map[string]map[string]interface{} {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.TrimSuffix(bytes.TrimPrefix(bytes.TrimSpace(data), []byte("ld(")), []byte(");"))
+
+	var items map[string]map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
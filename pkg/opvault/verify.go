@@ -0,0 +1,169 @@
+package opvault
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidHMAC = errors.New("invalid item hmac")
+	ErrMisbanded   = errors.New("item is stored in the wrong band")
+	ErrOrphaned    = errors.New("attachment belongs to an unknown item")
+)
+
+// Problem describes an entry of a vault that failed verification.
+type Problem struct {
+	File string // relative to the profile directory
+	UUID string // empty when the whole file is affected
+	Err  error
+}
+
+func (p Problem) String() string {
+	if p.UUID == "" {
+		return fmt.Sprintf("%s: %s", p.File, p.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.File, p.UUID, p.Err)
+}
+
+// Verify checks every band, folder and attachment of a vault profile and
+// reports all the entries which are unparsable, corrupt or stored in the
+// wrong band. Unlike Open it does not stop at the first problem. An error
+// is only returned when the profile itself cannot be unlocked.
+func Verify(path, profileName, master string) ([]Problem, error) {
+	if profileName == "" {
+		profileName = "default"
+	}
+
+	var (
+		dir      = filepath.Join(path, profileName)
+		problems []Problem
+		items    = map[string]*Item{}
+	)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "profile.js"))
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := parseProfile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = profile.setMasterPassword(master)
+	if err != nil {
+		return nil, err
+	}
+
+	report := func(file, uuid string, err error) {
+		problems = append(problems, Problem{File: file, UUID: uuid, Err: err})
+	}
+
+	{ // bands
+		paths, err := filepath.Glob(filepath.Join(dir, "band_*.js"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			file := filepath.Base(path)
+			bandID := strings.TrimSuffix(strings.TrimPrefix(file, "band_"), ".js")
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				report(file, "", err)
+				continue
+			}
+
+			band, err := parseBand(data)
+			if err != nil {
+				report(file, "", err)
+				continue
+			}
+
+			for id, item := range band {
+				if item.UUID != id || !strings.HasPrefix(id, bandID) {
+					report(file, id, ErrMisbanded)
+				}
+				if !item.verifyHMAC(profile) {
+					report(file, id, ErrInvalidHMAC)
+				}
+				if err := item.decryptOverView(profile); err != nil {
+					report(file, id, fmt.Errorf("overview: %s", err))
+				}
+				if err := item.decryptData(profile); err != nil {
+					report(file, id, fmt.Errorf("details: %s", err))
+				}
+				items[item.UUID] = item
+			}
+		}
+	}
+
+	{ // folders
+		data, err := ioutil.ReadFile(filepath.Join(dir, "folders.js"))
+		if err == nil {
+			folders, err := parseFolders(data)
+			if err != nil {
+				report("folders.js", "", err)
+			}
+			for id, folder := range folders {
+				if folder == nil {
+					continue
+				}
+				if err := folder.decryptOverView(profile); err != nil {
+					report("folders.js", id, fmt.Errorf("overview: %s", err))
+				}
+			}
+		}
+	}
+
+	{ // attachments
+		paths, err := filepath.Glob(filepath.Join(dir, "*.attachment"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			file := filepath.Base(path)
+
+			attachment, err := readAttachment(path)
+			if err != nil {
+				report(file, "", err)
+				continue
+			}
+
+			if err := attachment.decryptOverView(profile); err != nil {
+				report(file, attachment.UUID, fmt.Errorf("overview: %s", err))
+			}
+
+			item := items[attachment.ItemUUID]
+			if item == nil || !strings.HasPrefix(file, attachment.ItemUUID+"_") {
+				report(file, attachment.UUID, ErrOrphaned)
+				continue
+			}
+
+			if _, err := attachment.contents(item, profile); err != nil {
+				report(file, attachment.UUID, fmt.Errorf("contents: %s", err))
+			}
+		}
+	}
+
+	sort.Sort(problemsByFile(problems))
+
+	return problems, nil
+}
+
+type problemsByFile []Problem
+
+func (s problemsByFile) Len() int { return len(s) }
+func (s problemsByFile) Less(i, j int) bool {
+	if s[i].File != s[j].File {
+		return s[i].File < s[j].File
+	}
+	return s[i].UUID < s[j].UUID
+}
+func (s problemsByFile) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,56 @@
+package opvault
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeJSONP writes v as JSON wrapped in prefix and suffix (like
+// `ld(...);`), which is the format of all the files in an OPVault profile.
+func writeJSONP(path, prefix, suffix string, v interface{}) error {
+	var buf bytes.Buffer
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString(prefix)
+	buf.Write(data)
+	buf.WriteString(suffix)
+
+	return writeFileAtomic(path, buf.Bytes(), 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp, perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
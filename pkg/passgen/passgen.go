@@ -0,0 +1,267 @@
+// Package passgen generates random passwords and passphrases and estimates
+// their strength.
+package passgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+	// Ambiguous are the characters which are easily confused with one
+	// another in many fonts.
+	Ambiguous = "0O1Il|`'\""
+)
+
+var (
+	ErrEmptyClass = errors.New("passgen: character class is empty")
+	ErrLength     = errors.New("passgen: length must be positive")
+)
+
+// Password is a generated password and the entropy of the generator in
+// bits.
+type Password struct {
+	Value   string
+	Entropy float64
+}
+
+// Class is a set of characters a password is made of, and how many of them
+// it contains at least.
+type Class struct {
+	Chars string
+	Min   int
+}
+
+// Policy describes a random password.
+type Policy struct {
+	Length  int
+	Classes []Class
+
+	// Avoid lists characters which must not be used (like Ambiguous).
+	Avoid string
+}
+
+// Generate returns a random password following the policy. The entropy is
+// that of a password of the same length drawn from all the classes
+// together; the minimums of the classes lower it slightly.
+func Generate(p *Policy) (*Password, error) {
+	if p.Length <= 0 {
+		return nil, ErrLength
+	}
+
+	var (
+		all   []rune
+		seen  = map[rune]bool{}
+		chars []rune
+		total int
+	)
+
+	for _, c := range p.Classes {
+		set := filter(c.Chars, p.Avoid)
+		if len(set) == 0 {
+			return nil, ErrEmptyClass
+		}
+
+		for i := 0; i < c.Min; i++ {
+			r, err := pick(set)
+			if err != nil {
+				return nil, err
+			}
+			chars = append(chars, r)
+		}
+		total += c.Min
+
+		for _, r := range set {
+			if !seen[r] {
+				seen[r] = true
+				all = append(all, r)
+			}
+		}
+	}
+
+	if len(all) == 0 {
+		return nil, errors.New("passgen: no characters to choose from")
+	}
+	if total > p.Length {
+		return nil, errors.New("passgen: password too short for the required characters")
+	}
+
+	for len(chars) < p.Length {
+		r, err := pick(all)
+		if err != nil {
+			return nil, err
+		}
+		chars = append(chars, r)
+	}
+
+	// The required characters were added first; spread them out.
+	for i := len(chars) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+
+	return &Password{
+		Value:   string(chars),
+		Entropy: float64(p.Length) * math.Log2(float64(len(all))),
+	}, nil
+}
+
+const (
+	consonants = "bcdfghjklmnprstvwxz"
+	vowels     = "aeiou"
+)
+
+// Pronounceable returns a password of alternating consonants and vowels,
+// which is easier to read out and type than a fully random one but needs
+// to be longer for the same strength.
+func Pronounceable(length int, avoid string) (*Password, error) {
+	if length <= 0 {
+		return nil, ErrLength
+	}
+
+	cs, vs := filter(consonants, avoid), filter(vowels, avoid)
+	if len(cs) == 0 || len(vs) == 0 {
+		return nil, ErrEmptyClass
+	}
+
+	// Start with a consonant or a vowel at random.
+	n, err := randInt(2)
+	if err != nil {
+		return nil, err
+	}
+
+	chars := make([]rune, length)
+	entropy := 1.0
+	for i := range chars {
+		set := cs
+		if (i+n)%2 == 1 {
+			set = vs
+		}
+		if chars[i], err = pick(set); err != nil {
+			return nil, err
+		}
+		entropy += math.Log2(float64(len(set)))
+	}
+
+	return &Password{Value: string(chars), Entropy: entropy}, nil
+}
+
+// Passphrase returns words picked at random from Words, joined by sep.
+func Passphrase(words int, sep string, capitalize bool) (*Password, error) {
+	if words <= 0 {
+		return nil, ErrLength
+	}
+
+	list := make([]string, words)
+	for i := range list {
+		n, err := randInt(len(Words))
+		if err != nil {
+			return nil, err
+		}
+		list[i] = Words[n]
+		if capitalize {
+			list[i] = strings.ToUpper(list[i][:1]) + list[i][1:]
+		}
+	}
+
+	return &Password{
+		Value:   strings.Join(list, sep),
+		Entropy: float64(words) * math.Log2(float64(len(Words))),
+	}, nil
+}
+
+// Estimate guesses the entropy of a password in bits from the classes of
+// characters it uses. Repeated characters and runs like "abc" or "321" add
+// next to nothing. It is a rough upper bound: it does not know about
+// dictionary words or common substitutions.
+func Estimate(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case strings.ContainsRune(Lower, r):
+			lower = true
+		case strings.ContainsRune(Upper, r):
+			upper = true
+		case strings.ContainsRune(Digits, r):
+			digit = true
+		case strings.ContainsRune(Symbols, r) || r == ' ':
+			symbol = true
+		case unicode.IsPrint(r):
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, c := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.used {
+			pool += c.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	bits := math.Log2(float64(pool))
+
+	var (
+		entropy float64
+		prev    rune
+		step    rune
+	)
+	for i, r := range password {
+		switch d := r - prev; {
+		case i == 0:
+			entropy += bits
+		case d == 0 || (d == step && (d == 1 || d == -1)):
+			entropy++
+		default:
+			entropy += bits
+		}
+		step, prev = r-prev, r
+	}
+
+	return entropy
+}
+
+// filter returns the characters of s which are not in avoid.
+func filter(s, avoid string) []rune {
+	var rs []rune
+	for _, r := range s {
+		if !strings.ContainsRune(avoid, r) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+func pick(set []rune) (rune, error) {
+	n, err := randInt(len(set))
+	if err != nil {
+		return 0, err
+	}
+	return set[n], nil
+}
+
+// randInt returns a uniformly distributed number in [0, n).
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
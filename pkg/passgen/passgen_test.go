@@ -0,0 +1,25 @@
+package passgen
+
+import "testing"
+
+func TestLength(t *testing.T) {
+	for _, n := range []int{-1, 0} {
+		if _, err := Generate(&Policy{Length: n, Classes: []Class{{Chars: Lower}}}); err != ErrLength {
+			t.Errorf("Generate(%d): %v, want ErrLength", n, err)
+		}
+		if _, err := Pronounceable(n, ""); err != ErrLength {
+			t.Errorf("Pronounceable(%d): %v, want ErrLength", n, err)
+		}
+		if _, err := Passphrase(n, "-", false); err != ErrLength {
+			t.Errorf("Passphrase(%d): %v, want ErrLength", n, err)
+		}
+	}
+
+	p, err := Generate(&Policy{Length: 16, Classes: []Class{{Chars: Lower, Min: 1}, {Chars: Digits, Min: 1}}, Avoid: Ambiguous})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(p.Value)); n != 16 {
+		t.Errorf("Generate: %d characters, want 16", n)
+	}
+}
@@ -0,0 +1,211 @@
+package passgen
+
+// Words is the list passphrases are made of: 2048 short, common English
+// words, so every word adds 11 bits of entropy.
+var Words = []string{
+	"able", "acid", "acorn", "acre", "act", "actor", "adapt", "add", "adobe", "adult",
+	"aero", "afar", "affix", "age", "agent", "agile", "aging", "ahead", "aide", "aim",
+	"air", "aisle", "alarm", "album", "alert", "algae", "alias", "alibi", "alien", "align",
+	"alike", "alive", "alley", "allow", "alloy", "ally", "aloe", "alpha", "alps", "altar",
+	"alter", "amber", "amble", "amend", "amino", "ample", "amuse", "angel", "angle", "ankle",
+	"annex", "antler", "anvil", "apex", "apple", "april", "apron", "aqua", "arbor", "arch",
+	"arena", "argue", "arise", "armor", "army", "aroma", "arrow", "art", "ascot", "ash",
+	"aside", "ask", "aspen", "asset", "atlas", "atom", "attic", "audio", "audit", "aunt",
+	"auto", "avoid", "awake", "award", "aware", "axis", "bacon", "badge", "bagel", "baggy",
+	"baker", "balmy", "bamboo", "banjo", "barn", "baron", "basil", "basin", "batch", "bath",
+	"baton", "bay", "beach", "beads", "beam", "bean", "bear", "beast", "bee", "beef",
+	"beep", "beet", "begin", "beige", "bell", "belt", "bench", "berry", "bevel", "bias",
+	"bike", "bingo", "birch", "bird", "bison", "blade", "blank", "blast", "blaze", "blend",
+	"bless", "blimp", "blink", "bliss", "block", "blond", "bloom", "blue", "blunt", "blur",
+	"blush", "board", "boat", "body", "boil", "bolt", "bonus", "book", "boost", "boot",
+	"booth", "boss", "bound", "bowl", "box", "brain", "brake", "brand", "brass", "brave",
+	"bread", "break", "brick", "bride", "brief", "brim", "bring", "brisk", "broad", "broil",
+	"brook", "broom", "brown", "brush", "buck", "buddy", "budge", "buggy", "bugle", "build",
+	"bulb", "bulk", "bunch", "bunny", "burst", "bush", "butter", "buzz", "cabin", "cable",
+	"cactus", "cadet", "cage", "cake", "calf", "call", "calm", "camel", "camp", "canal",
+	"candy", "canoe", "canon", "canyon", "cape", "card", "cargo", "carol", "carry", "cart",
+	"carve", "case", "cash", "cast", "cat", "catch", "cause", "cave", "cedar", "cello",
+	"chair", "chalk", "champ", "chant", "charm", "chart", "chase", "cheek", "cheer", "chef",
+	"chess", "chest", "chew", "chick", "chief", "child", "chili", "chill", "chime", "chin",
+	"chip", "chirp", "choir", "chop", "chord", "chow", "chunk", "cider", "cigar", "cinch",
+	"circus", "city", "civic", "civil", "clad", "claim", "clam", "clamp", "clap", "clash",
+	"clasp", "class", "claw", "clay", "clean", "clear", "clerk", "click", "cliff", "climb",
+	"cling", "clip", "cloak", "clock", "clone", "close", "cloth", "cloud", "clown", "club",
+	"clue", "coach", "coast", "cobra", "cocoa", "coil", "coin", "cola", "cold", "comet",
+	"comic", "coral", "cord", "core", "corn", "couch", "cough", "count", "cove", "cover",
+	"cozy", "crab", "craft", "crane", "crank", "crate", "crave", "crawl", "crayon", "crazy",
+	"cream", "creek", "crepe", "crest", "crew", "crib", "crisp", "crop", "cross", "crowd",
+	"crown", "crumb", "crush", "crust", "cube", "cupid", "curb", "curl", "curry", "curve",
+	"cycle", "cymbal", "dab", "dad", "daily", "dairy", "daisy", "dance", "dandy", "dare",
+	"dart", "dash", "data", "date", "dawn", "deal", "debit", "debut", "decaf", "decal",
+	"decay", "decor", "decoy", "deer", "delay", "delta", "demo", "denim", "dense", "dent",
+	"depot", "depth", "derby", "desk", "dial", "diary", "dice", "diet", "dig", "digit",
+	"dill", "dime", "diner", "dingo", "dip", "dish", "disk", "ditch", "ditto", "diver",
+	"dizzy", "dock", "dodge", "doing", "doll", "dome", "donor", "donut", "door", "dose",
+	"dot", "dough", "dove", "down", "dozen", "draft", "drain", "drama", "drape", "draw",
+	"dream", "dress", "dried", "drift", "drill", "drink", "drip", "drive", "drone", "drool",
+	"drop", "drum", "dry", "duck", "duct", "duet", "duke", "dune", "dusk", "dust",
+	"duty", "dwarf", "dwell", "eager", "eagle", "early", "earth", "easel", "east", "easy",
+	"eaten", "eats", "ebony", "echo", "edge", "edict", "edit", "eel", "egg", "eight",
+	"elbow", "elder", "elect", "elf", "elite", "elk", "elm", "elope", "elude", "email",
+	"ember", "emblem", "emcee", "emit", "empty", "emu", "enact", "end", "endow", "enjoy",
+	"enter", "entry", "envoy", "epic", "equal", "equip", "erase", "erupt", "essay", "ether",
+	"evade", "even", "event", "every", "evict", "evoke", "exact", "exam", "excel", "exile",
+	"exit", "expel", "extra", "fable", "fabric", "face", "facet", "fact", "fade", "fair",
+	"fairy", "faith", "fall", "false", "fame", "fancy", "fang", "farm", "fast", "fax",
+	"feast", "feat", "fedora", "feed", "fence", "fern", "ferry", "fetch", "fever", "fiber",
+	"fiddle", "field", "fig", "film", "final", "finch", "find", "fire", "firm", "first",
+	"fish", "five", "fix", "fizz", "flag", "flair", "flake", "flame", "flap", "flash",
+	"flask", "flat", "flavor", "flax", "flea", "fleet", "flex", "flick", "flier", "fling",
+	"flint", "flip", "float", "flock", "flood", "floor", "flora", "floss", "flour", "flow",
+	"fluid", "fluke", "flute", "flyer", "foam", "focal", "focus", "foe", "fog", "foil",
+	"fold", "folk", "font", "food", "fool", "foot", "force", "forge", "fork", "form",
+	"fort", "forty", "forum", "fossil", "found", "fox", "foyer", "frail", "frame", "fresh",
+	"friar", "fridge", "fries", "frill", "frog", "front", "frost", "frown", "froze", "fruit",
+	"fudge", "fuel", "fully", "fume", "fund", "fungi", "funny", "fur", "fuse", "fuzzy",
+	"gab", "gadget", "gag", "gain", "gala", "galaxy", "gale", "game", "gamma", "gap",
+	"garage", "garden", "garlic", "gas", "gasp", "gate", "gauge", "gauze", "gave", "gazebo",
+	"gear", "gecko", "geese", "gem", "genie", "genre", "ghost", "giant", "giddy", "gift",
+	"ginger", "given", "giver", "glad", "glade", "gland", "glare", "glass", "glaze", "gleam",
+	"glide", "glint", "globe", "glory", "glove", "glow", "glue", "gnome", "goal", "goat",
+	"gold", "golf", "gong", "good", "goose", "gorge", "gown", "grab", "grace", "grade",
+	"grain", "grand", "grant", "grape", "graph", "grasp", "grass", "gravy", "gray", "great",
+	"green", "greet", "grid", "grill", "grin", "grip", "grit", "groom", "group", "grove",
+	"growl", "grown", "guard", "guava", "guess", "guest", "guide", "guild", "guitar", "gulf",
+	"gull", "gully", "gum", "guru", "gust", "habit", "hair", "half", "hall", "halo",
+	"halt", "ham", "hammer", "hamper", "hand", "handy", "hang", "happy", "harbor", "hard",
+	"harp", "hash", "hasty", "hat", "hatch", "haven", "hawk", "haze", "hazel", "head",
+	"heap", "heart", "heat", "heavy", "hedge", "heel", "hefty", "height", "helix", "hello",
+	"helm", "help", "hemp", "hen", "herb", "herd", "hero", "heron", "hiker", "hill",
+	"hinge", "hint", "hippo", "hire", "hive", "hobby", "hockey", "hold", "hole", "holly",
+	"home", "honey", "hood", "hoof", "hook", "hoop", "hop", "horn", "horse", "hose",
+	"host", "hotel", "hound", "hour", "house", "hover", "howl", "hub", "huddle", "hug",
+	"hula", "hull", "human", "humid", "humor", "hump", "hunch", "hunk", "hunt", "hurry",
+	"husky", "hut", "hydro", "hyena", "hymn", "ice", "icing", "icon", "icy", "idea",
+	"ideal", "idiom", "idle", "idol", "igloo", "image", "imply", "inbox", "inch", "index",
+	"indoor", "infer", "ink", "inlet", "inner", "input", "intro", "iris", "iron", "irony",
+	"island", "issue", "itch", "item", "ivory", "ivy", "jab", "jacket", "jade", "jaguar",
+	"jam", "jar", "jargon", "jaw", "jazz", "jeans", "jeep", "jelly", "jersey", "jest",
+	"jet", "jewel", "jiffy", "jigsaw", "jingle", "job", "jockey", "jog", "join", "joint",
+	"joke", "jolly", "jolt", "journal", "joy", "judge", "juice", "juicy", "july", "jumbo",
+	"jump", "june", "jungle", "junior", "junk", "jury", "just", "kale", "kayak", "kazoo",
+	"keel", "keen", "keep", "kelp", "kennel", "kept", "kettle", "key", "khaki", "kick",
+	"kid", "kidney", "kilt", "kind", "king", "kiosk", "kite", "kitten", "kiwi", "knack",
+	"knee", "knelt", "knife", "knit", "knob", "knock", "knot", "known", "koala", "kudos",
+	"lab", "label", "lace", "ladder", "ladle", "lady", "lagoon", "lake", "lamb", "lamp",
+	"lance", "land", "lane", "lap", "lapel", "large", "laser", "lasso", "latch", "late",
+	"lathe", "latte", "laugh", "lava", "lawn", "layer", "lazy", "leaf", "leak", "lean",
+	"leap", "learn", "lease", "leash", "leave", "ledge", "leek", "left", "legal", "lemon",
+	"lend", "lens", "lentil", "level", "lever", "lid", "lift", "light", "lilac", "lily",
+	"limb", "lime", "limit", "limp", "line", "linen", "lint", "lion", "lip", "liquid",
+	"list", "liter", "live", "lizard", "llama", "load", "loaf", "loan", "lobby", "lobe",
+	"local", "lock", "lodge", "loft", "logic", "logo", "long", "loom", "loop", "loose",
+	"lotus", "loud", "lounge", "love", "loyal", "lucky", "lumber", "lump", "lunar", "lunch",
+	"lung", "lure", "lurk", "lush", "lyric", "macaw", "macro", "madam", "magic", "magma",
+	"magnet", "maid", "mail", "main", "major", "maker", "mango", "manor", "mantle", "maple",
+	"marble", "march", "mare", "margin", "marina", "mark", "marsh", "mascot", "mask", "mason",
+	"mast", "match", "mate", "math", "mauve", "maze", "meadow", "meal", "mean", "meat",
+	"medal", "media", "medic", "meet", "melon", "melt", "memo", "menu", "mercy", "merge",
+	"merit", "merry", "mesh", "metal", "meter", "metro", "midst", "might", "mild", "mile",
+	"milk", "mill", "mime", "mimic", "mince", "mind", "mini", "mink", "minor", "mint",
+	"minus", "mirror", "mist", "mitten", "mix", "moat", "mocha", "model", "modem", "mold",
+	"mole", "molt", "money", "monk", "month", "moody", "moose", "mop", "moral", "morse",
+	"moss", "motel", "moth", "motor", "motto", "mound", "mount", "mouse", "mouth", "move",
+	"movie", "mower", "much", "mud", "muffin", "mug", "mule", "mural", "muse", "music",
+	"musky", "mute", "mutt", "myth", "nacho", "nail", "name", "nap", "napkin", "narrow",
+	"nasal", "navy", "near", "neat", "neck", "nectar", "need", "needle", "neon", "nephew",
+	"nerve", "nest", "net", "never", "new", "next", "nice", "niche", "nickel", "night",
+	"nimble", "nine", "ninja", "noble", "nod", "node", "noise", "nomad", "none", "noodle",
+	"noon", "north", "nose", "notch", "note", "noun", "novel", "nudge", "null", "number",
+	"nurse", "nut", "nylon", "oak", "oar", "oasis", "oat", "obey", "object", "oboe",
+	"ocean", "octave", "odd", "odor", "offer", "office", "often", "oil", "okay", "old",
+	"olive", "omega", "omen", "omit", "onion", "online", "only", "onset", "onto", "onward",
+	"opal", "open", "opera", "optic", "oracle", "orange", "orbit", "orchid", "order", "organ",
+	"orient", "origin", "other", "otter", "ounce", "outer", "outfit", "oval", "oven", "over",
+	"owl", "owner", "oxen", "oxide", "oyster", "ozone", "pace", "pack", "pact", "paddle",
+	"page", "pager", "pail", "paint", "pajama", "palace", "pale", "palm", "panda", "panel",
+	"panic", "pansy", "pants", "papaya", "paper", "parade", "parcel", "parch", "park", "parrot",
+	"party", "pass", "pasta", "paste", "pastry", "patch", "path", "patio", "patrol", "pause",
+	"pave", "paw", "peace", "peach", "peak", "peanut", "pear", "pearl", "pecan", "pedal",
+	"peel", "peer", "pelican", "pen", "pencil", "penny", "pepper", "perch", "perky", "pest",
+	"petal", "petty", "phase", "phone", "photo", "piano", "pick", "pickle", "picnic", "pie",
+	"piece", "pier", "piggy", "pilot", "pinch", "pine", "pink", "pint", "pipe", "pirate",
+	"pitch", "pivot", "pixel", "pizza", "place", "plaid", "plain", "plan", "plane", "plank",
+	"plant", "plate", "plaza", "plead", "pleat", "plot", "plow", "pluck", "plug", "plum",
+	"plump", "plus", "pocket", "poem", "poet", "point", "poise", "poker", "polar", "pole",
+	"polka", "polo", "pond", "pony", "poodle", "pool", "poppy", "porch", "port", "pose",
+	"posh", "post", "pouch", "pound", "power", "prank", "prawn", "press", "price", "pride",
+	"prime", "print", "prism", "prize", "probe", "promo", "proof", "prose", "proud", "prune",
+	"pub", "puck", "puddle", "puff", "pulp", "pulse", "puma", "pump", "punch", "pupil",
+	"puppy", "purple", "purse", "push", "puzzle", "pylon", "quack", "quail", "quake", "qualm",
+	"quart", "queen", "query", "quest", "queue", "quick", "quiet", "quill", "quilt", "quirk",
+	"quit", "quota", "quote", "rabbit", "race", "rack", "radar", "radio", "raft", "rage",
+	"raid", "rail", "rain", "raise", "rake", "rally", "ramp", "ranch", "range", "rapid",
+	"rash", "raven", "ravine", "raw", "razor", "reach", "read", "ready", "realm", "reap",
+	"rebel", "recap", "recipe", "reef", "reel", "refer", "regal", "rehab", "reign", "relax",
+	"relay", "relic", "remix", "remote", "renew", "rent", "repay", "reply", "rerun", "rescue",
+	"resin", "rest", "retro", "reuse", "rhino", "rhyme", "rib", "ribbon", "rice", "rich",
+	"ride", "ridge", "rifle", "rigid", "rim", "ring", "rinse", "ripe", "ripple", "rise",
+	"risk", "rival", "river", "road", "roast", "robe", "robin", "robot", "rock", "rocket",
+	"rodeo", "role", "roll", "roof", "rookie", "room", "roost", "root", "rope", "rose",
+	"rosy", "rotor", "rouge", "rough", "round", "route", "rover", "rowdy", "royal", "rubber",
+	"ruby", "rudder", "rug", "rugby", "ruler", "rumble", "rung", "rural", "rush", "rust",
+	"saddle", "safari", "safe", "saga", "sage", "sail", "salad", "salmon", "salon", "salsa",
+	"salt", "salute", "same", "sample", "sand", "sandal", "sane", "sash", "satin", "sauce",
+	"sauna", "save", "savvy", "scale", "scalp", "scan", "scarf", "scene", "scent", "scone",
+	"scoop", "scope", "score", "scout", "scrap", "screw", "scrub", "scuba", "sea", "seal",
+	"seam", "season", "seat", "second", "sedan", "seed", "seek", "seesaw", "self", "sense",
+	"sepia", "serum", "serve", "setup", "seven", "shade", "shaft", "shake", "shale", "shallow",
+	"shape", "share", "shark", "sharp", "shawl", "sheep", "sheet", "shelf", "shell", "shield",
+	"shift", "shine", "ship", "shirt", "shoe", "shop", "shore", "short", "shout", "shovel",
+	"show", "shrub", "shrug", "shy", "sift", "sigh", "sign", "silk", "silly", "silver",
+	"simple", "siren", "sister", "sitar", "size", "skate", "sketch", "ski", "skid", "skill",
+	"skim", "skirt", "skull", "sky", "slab", "slate", "sled", "sleek", "sleep", "sleet",
+	"slice", "slide", "slim", "sling", "slope", "slot", "sloth", "slow", "slug", "slush",
+	"small", "smart", "smile", "smog", "smoke", "snack", "snail", "snake", "snap", "snare",
+	"sneak", "sniff", "snore", "snout", "snow", "snug", "soap", "soccer", "sock", "soda",
+	"sofa", "soft", "solar", "solid", "solo", "sonar", "song", "sonic", "soon", "sorry",
+	"sort", "soul", "sound", "soup", "sour", "south", "space", "spade", "spark", "spawn",
+	"speak", "spear", "speed", "spell", "spice", "spider", "spike", "spill", "spin", "spine",
+	"spire", "spoke", "sponge", "spoon", "sport", "spot", "spout", "spray", "spree", "spring",
+	"sprout", "spruce", "spur", "squad", "squid", "stack", "staff", "stage", "stair", "stake",
+	"stamp", "stand", "star", "start", "stash", "state", "steak", "steam", "steel", "steep",
+	"stem", "step", "stew", "stick", "still", "sting", "stir", "stock", "stomp", "stone",
+	"stool", "storm", "story", "stove", "straw", "stream", "street", "strip", "stripe", "stroll",
+	"strum", "stub", "stuck", "study", "stuff", "stump", "stunt", "style", "sugar", "suit",
+	"sum", "summer", "summit", "sun", "sunny", "super", "surf", "surge", "sushi", "swamp",
+	"swan", "swap", "swarm", "sway", "sweat", "sweep", "sweet", "swell", "swift", "swim",
+	"swing", "swirl", "sword", "syrup", "table", "tablet", "taco", "tag", "tail", "tailor",
+	"talent", "talk", "tall", "tame", "tango", "tank", "tape", "target", "tart", "task",
+	"taste", "tavern", "taxi", "tea", "teach", "team", "teapot", "tease", "teen", "tell",
+	"tempo", "ten", "tend", "tennis", "tent", "term", "test", "text", "thaw", "theme",
+	"thick", "thigh", "thing", "think", "thorn", "thread", "three", "thrift", "throne", "thumb",
+	"thump", "tiara", "ticket", "tidal", "tide", "tidy", "tiger", "tight", "tile", "tilt",
+	"timber", "time", "timid", "tint", "tiny", "tip", "tire", "title", "toast", "today",
+	"toe", "toffee", "token", "tomato", "tone", "tongs", "tonic", "tool", "tooth", "topic",
+	"torch", "tornado", "total", "totem", "touch", "tough", "tour", "towel", "tower", "town",
+	"toy", "trace", "track", "trade", "trail", "train", "tram", "trap", "tray", "treat",
+	"tree", "trek", "trend", "trial", "tribe", "trick", "trim", "trio", "trip", "troll",
+	"trophy", "trout", "truck", "true", "trunk", "trust", "truth", "tuba", "tube", "tulip",
+	"tumble", "tuna", "tune", "tunnel", "turbo", "turf", "turkey", "turn", "turtle", "tusk",
+	"tutor", "tuxedo", "tweak", "tweed", "twig", "twin", "twist", "type", "udder", "ultra",
+	"umpire", "uncle", "under", "undo", "unfit", "union", "unit", "unity", "unlock", "untie",
+	"until", "unused", "update", "upon", "upper", "upset", "urban", "urge", "usage", "used",
+	"user", "usher", "usual", "utter", "vacuum", "vague", "valid", "valley", "valve", "vanilla",
+	"vapor", "vase", "vault", "vector", "veggie", "veil", "vein", "velvet", "vendor", "venue",
+	"verb", "verse", "vessel", "vest", "veto", "vial", "vibe", "video", "view", "vigor",
+	"villa", "vine", "vinyl", "viola", "violin", "viper", "virus", "visa", "visit", "visor",
+	"vista", "vital", "vivid", "vocal", "vogue", "voice", "volt", "vote", "voter", "vowel",
+	"voyage", "wade", "wafer", "waffle", "wage", "wagon", "waist", "wait", "wake", "walk",
+	"wall", "walnut", "walrus", "wand", "want", "ward", "warm", "warp", "wash", "wasp",
+	"watch", "water", "watt", "wave", "wavy", "wax", "weary", "weave", "web", "wedge",
+	"weed", "week", "weigh", "well", "west", "wet", "whale", "wheat", "wheel", "whiff",
+	"whim", "whip", "whisk", "white", "whole", "wick", "wide", "width", "wife", "wiggle",
+	"wild", "will", "willow", "wind", "window", "wing", "wink", "winter", "wire", "wise",
+	"wish", "wit", "witty", "wizard", "wobble", "wok", "wolf", "woman", "wonder", "wood",
+	"wool", "word", "work", "world", "worm", "worry", "worth", "wound", "woven", "wrap",
+	"wreath", "wreck", "wren", "wrist", "write", "yacht", "yak", "yam", "yard", "yarn",
+	"yawn", "year", "yeast", "yell", "yellow", "yelp", "yeti", "yield", "yodel", "yoga",
+	"yogurt", "yolk", "young", "youth", "yoyo", "yummy", "zap", "zeal", "zebra", "zero",
+	"zest", "zigzag", "zinc", "zipper", "zodiac", "zombie", "zone", "zoom",
+}
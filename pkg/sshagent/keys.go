@@ -0,0 +1,322 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Key is a private key served by the agent.
+type Key struct {
+	Signer  crypto.Signer
+	Comment string
+
+	blob []byte
+}
+
+var (
+	ErrEncrypted = errors.New("ssh: encrypted private keys are not supported")
+)
+
+// ParseKeys finds all the PEM encoded private keys in data. Blocks which
+// are not (supported) private keys are reported as errors.
+func ParseKeys(data []byte, comment string) ([]*Key, []error) {
+	var (
+		keys []*Key
+		errs []error
+	)
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		signer, err := parsePrivateKey(block)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		key, err := NewKey(signer, comment)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, errs
+}
+
+func NewKey(signer crypto.Signer, comment string) (*Key, error) {
+	blob, err := publicKeyBlob(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{Signer: signer, Comment: comment, blob: blob}, nil
+}
+
+// Type returns the SSH name of the key type.
+func (k *Key) Type() string {
+	r := reader{buf: k.blob}
+	return r.string()
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if _, f := block.Headers["DEK-Info"]; f {
+		return nil, ErrEncrypted
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "OPENSSH PRIVATE KEY":
+		key, err = parseOpenSSHPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("ssh: unsupported key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	case *ed25519.PrivateKey:
+		return *k, nil
+	default:
+		return nil, fmt.Errorf("ssh: unsupported key type %T", key)
+	}
+}
+
+// parseOpenSSHPrivateKey parses the (unencrypted) openssh-key-v1 format.
+func parseOpenSSHPrivateKey(data []byte) (interface{}, error) {
+	const magic = "openssh-key-v1\x00"
+
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, errors.New("ssh: invalid openssh private key")
+	}
+
+	r := reader{buf: data[len(magic):]}
+	cipher := r.string()
+	kdf := r.string()
+	r.bytes() // kdf options
+	n := r.uint32()
+	r.bytes() // public key
+	private := r.bytes()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if cipher != "none" || kdf != "none" {
+		return nil, ErrEncrypted
+	}
+	if n != 1 {
+		return nil, errors.New("ssh: multiple keys in one file are not supported")
+	}
+
+	r = reader{buf: private}
+	if r.uint32() != r.uint32() {
+		return nil, errors.New("ssh: invalid openssh private key check bytes")
+	}
+
+	var key interface{}
+
+	switch typ := r.string(); typ {
+	case "ssh-ed25519":
+		r.bytes() // public key
+		priv := r.bytes()
+		if r.err == nil && len(priv) != ed25519.PrivateKeySize {
+			return nil, errors.New("ssh: invalid ed25519 private key")
+		}
+		key = ed25519.PrivateKey(priv)
+
+	case "ssh-rsa":
+		k := &rsa.PrivateKey{}
+		k.N = r.mpint()
+		e := r.mpint()
+		k.D = r.mpint()
+		r.mpint() // iqmp
+		p := r.mpint()
+		q := r.mpint()
+		if r.err != nil {
+			return nil, r.err
+		}
+		k.E = int(e.Int64())
+		k.Primes = []*big.Int{p, q}
+		if err := k.Validate(); err != nil {
+			return nil, err
+		}
+		k.Precompute()
+		key = k
+
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		curve := curveFor(r.string())
+		point := r.bytes()
+		d := r.mpint()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if curve == nil {
+			return nil, errors.New("ssh: unsupported curve")
+		}
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return nil, errors.New("ssh: invalid ecdsa public key")
+		}
+		key = &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: d}
+
+	default:
+		return nil, fmt.Errorf("ssh: unsupported key type %q", typ)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return key, nil
+}
+
+func curveFor(name string) elliptic.Curve {
+	switch name {
+	case "nistp256":
+		return elliptic.P256()
+	case "nistp384":
+		return elliptic.P384()
+	case "nistp521":
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
+
+func curveName(curve elliptic.Curve) string {
+	switch curve {
+	case elliptic.P256():
+		return "nistp256"
+	case elliptic.P384():
+		return "nistp384"
+	case elliptic.P521():
+		return "nistp521"
+	default:
+		return ""
+	}
+}
+
+func publicKeyBlob(pub crypto.PublicKey) ([]byte, error) {
+	var w writer
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		w.string("ssh-rsa")
+		w.mpint(big.NewInt(int64(k.E)))
+		w.mpint(k.N)
+
+	case *ecdsa.PublicKey:
+		name := curveName(k.Curve)
+		if name == "" {
+			return nil, errors.New("ssh: unsupported curve")
+		}
+		w.string("ecdsa-sha2-" + name)
+		w.string(name)
+		w.bytes(elliptic.Marshal(k.Curve, k.X, k.Y))
+
+	case ed25519.PublicKey:
+		w.string("ssh-ed25519")
+		w.bytes(k)
+
+	default:
+		return nil, fmt.Errorf("ssh: unsupported key type %T", pub)
+	}
+
+	return w.buf, nil
+}
+
+const (
+	flagRSASHA256 = 2
+	flagRSASHA512 = 4
+)
+
+// sign returns the SSH encoded signature of data.
+func (k *Key) sign(data []byte, flags uint32) ([]byte, error) {
+	var w writer
+
+	switch s := k.Signer.(type) {
+	case *rsa.PrivateKey:
+		var (
+			algo = "ssh-rsa"
+			hash = crypto.SHA1
+		)
+		if flags&flagRSASHA512 != 0 {
+			algo, hash = "rsa-sha2-512", crypto.SHA512
+		} else if flags&flagRSASHA256 != 0 {
+			algo, hash = "rsa-sha2-256", crypto.SHA256
+		}
+		h := hash.New()
+		h.Write(data)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, s, hash, h.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+		w.string(algo)
+		w.bytes(sig)
+
+	case *ecdsa.PrivateKey:
+		hash := crypto.SHA256
+		switch s.Curve.Params().BitSize {
+		case 384:
+			hash = crypto.SHA384
+		case 521:
+			hash = crypto.SHA512
+		}
+		h := hash.New()
+		h.Write(data)
+		r, ss, err := ecdsa.Sign(rand.Reader, s, h.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+		var sig writer
+		sig.mpint(r)
+		sig.mpint(ss)
+		w.string(k.Type())
+		w.bytes(sig.buf)
+
+	case ed25519.PrivateKey:
+		w.string("ssh-ed25519")
+		w.bytes(ed25519.Sign(s, data))
+
+	default:
+		return nil, fmt.Errorf("ssh: unsupported key type %T", k.Signer)
+	}
+
+	return w.buf, nil
+}
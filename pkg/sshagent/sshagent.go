@@ -0,0 +1,179 @@
+// Package sshagent implements a read-only ssh-agent (the protocol spoken
+// over SSH_AUTH_SOCK) which serves keys that never leave memory.
+package sshagent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	msgFailure           = 5
+	msgRequestIdentities = 11
+	msgIdentitiesAnswer  = 12
+	msgSignRequest       = 13
+	msgSignResponse      = 14
+
+	maxMessageSize = 256 * 1024
+)
+
+// Agent serves the keys returned by Load. Load is called the first time the
+// keys are needed; its result is kept until Reset is called.
+type Agent struct {
+	// Load returns the keys to serve.
+	Load func() ([]*Key, error)
+
+	// Confirm (when set) is asked before every signature.
+	Confirm func(key *Key) bool
+
+	mu     sync.Mutex
+	keys   []*Key
+	loaded bool
+}
+
+// Reset forgets the loaded keys.
+func (a *Agent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.keys = nil
+	a.loaded = false
+}
+
+func (a *Agent) getKeys() ([]*Key, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loaded {
+		keys, err := a.Load()
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+		a.loaded = true
+	}
+
+	return a.keys, nil
+}
+
+// Serve accepts connections until the listener is closed. Every connection
+// is first passed to accept (when set) which may refuse it.
+func (a *Agent) Serve(l net.Listener, accept func(net.Conn) error) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		if accept != nil {
+			if err := accept(conn); err != nil {
+				conn.Close()
+				continue
+			}
+		}
+
+		go a.ServeConn(conn)
+	}
+}
+
+// ServeConn handles requests on a single connection until it is closed.
+func (a *Agent) ServeConn(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+
+	var header [4]byte
+	for {
+		_, err := io.ReadFull(conn, header[:])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		n := binary.BigEndian.Uint32(header[:])
+		if n == 0 || n > maxMessageSize {
+			return errors.New("ssh: invalid agent message size")
+		}
+
+		msg := make([]byte, n)
+		_, err = io.ReadFull(conn, msg)
+		if err != nil {
+			return err
+		}
+
+		reply := a.handle(msg)
+
+		var w writer
+		w.bytes(reply)
+		_, err = conn.Write(w.buf)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (a *Agent) handle(msg []byte) []byte {
+	switch msg[0] {
+
+	case msgRequestIdentities:
+		keys, err := a.getKeys()
+		if err != nil {
+			return []byte{msgFailure}
+		}
+
+		var w writer
+		w.byte(msgIdentitiesAnswer)
+		w.uint32(uint32(len(keys)))
+		for _, key := range keys {
+			w.bytes(key.blob)
+			w.string(key.Comment)
+		}
+		return w.buf
+
+	case msgSignRequest:
+		r := reader{buf: msg[1:]}
+		blob := r.bytes()
+		data := r.bytes()
+		flags := r.uint32()
+		if r.err != nil {
+			return []byte{msgFailure}
+		}
+
+		keys, err := a.getKeys()
+		if err != nil {
+			return []byte{msgFailure}
+		}
+
+		for _, key := range keys {
+			if !bytes.Equal(key.blob, blob) {
+				continue
+			}
+
+			if a.Confirm != nil && !a.Confirm(key) {
+				return []byte{msgFailure}
+			}
+
+			sig, err := key.sign(data, flags)
+			if err != nil {
+				return []byte{msgFailure}
+			}
+
+			var w writer
+			w.byte(msgSignResponse)
+			w.bytes(sig)
+			return w.buf
+		}
+
+		return []byte{msgFailure}
+
+	default:
+		// adding and removing keys is not supported; the keys live in the
+		// vault.
+		return []byte{msgFailure}
+
+	}
+}
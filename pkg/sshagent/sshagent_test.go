@@ -0,0 +1,189 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The keys in testdata were generated by ssh-keygen (without a passphrase).
+var testKeys = []struct {
+	file, typ string
+}{
+	{"id_rsa", "ssh-rsa"},
+	{"id_ecdsa", "ecdsa-sha2-nistp256"},
+	{"id_ed25519", "ssh-ed25519"},
+}
+
+func loadKey(t *testing.T, file string) (*Key, []byte) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, errs := ParseKeys(data, file)
+	if len(errs) > 0 {
+		t.Fatalf("ParseKeys(%s): %v", file, errs)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("ParseKeys(%s) = %d keys, want 1", file, len(keys))
+	}
+
+	// the public key file holds the blob the agent must serve
+	data, err = ioutil.ReadFile(filepath.Join("testdata", file+".pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		t.Fatalf("invalid public key %s.pub", file)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys[0], blob
+}
+
+func TestParseKeys(t *testing.T) {
+	for _, test := range testKeys {
+		key, blob := loadKey(t, test.file)
+
+		if key.Type() != test.typ {
+			t.Errorf("%s: type %q, want %q", test.file, key.Type(), test.typ)
+		}
+		if !bytes.Equal(key.blob, blob) {
+			t.Errorf("%s: public key does not match %s.pub", test.file, test.file)
+		}
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	var keys []*Key
+	for _, test := range testKeys {
+		key, _ := loadKey(t, test.file)
+		keys = append(keys, key)
+	}
+
+	agent := &Agent{Load: func() ([]*Key, error) { return keys, nil }}
+	client, server := net.Pipe()
+	defer client.Close()
+	go agent.ServeConn(server)
+
+	var w writer
+	w.byte(msgRequestIdentities)
+	r := request(t, client, w.buf)
+	if msg := r.buf[0]; msg != msgIdentitiesAnswer {
+		t.Fatalf("request identities: reply %d", msg)
+	}
+	r.buf = r.buf[1:]
+	if n := r.uint32(); n != uint32(len(keys)) {
+		t.Fatalf("request identities: %d keys, want %d", n, len(keys))
+	}
+	for _, key := range keys {
+		blob, comment := r.bytes(), r.string()
+		if !bytes.Equal(blob, key.blob) || comment != key.Comment {
+			t.Errorf("request identities: got %s, want %s", comment, key.Comment)
+		}
+	}
+
+	data := []byte("session data")
+	for _, key := range keys {
+		w = writer{}
+		w.byte(msgSignRequest)
+		w.bytes(key.blob)
+		w.bytes(data)
+		w.uint32(flagRSASHA256)
+		r = request(t, client, w.buf)
+		if msg := r.buf[0]; msg != msgSignResponse {
+			t.Errorf("%s: sign request: reply %d", key.Comment, msg)
+			continue
+		}
+		r.buf = r.buf[1:]
+		sig := reader{buf: r.bytes()}
+		algo, blob := sig.string(), sig.bytes()
+		if sig.err != nil {
+			t.Fatal(sig.err)
+		}
+
+		if !verify(key.Signer.Public(), algo, data, blob) {
+			t.Errorf("%s: invalid %s signature", key.Comment, algo)
+		}
+	}
+
+	// unknown keys are not signed for
+	w = writer{}
+	w.byte(msgSignRequest)
+	w.bytes([]byte("unknown"))
+	w.bytes(data)
+	w.uint32(0)
+	if r := request(t, client, w.buf); r.buf[0] != msgFailure {
+		t.Errorf("sign request for unknown key: reply %d", r.buf[0])
+	}
+}
+
+// request sends an agent message and returns the reply.
+func request(t *testing.T, conn net.Conn, msg []byte) *reader {
+	var w writer
+	w.bytes(msg)
+	_, err := conn.Write(w.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var header [4]byte
+	_, err = io.ReadFull(conn, header[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, binary.BigEndian.Uint32(header[:]))
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply) == 0 {
+		t.Fatal("empty reply")
+	}
+
+	return &reader{buf: reply}
+}
+
+func verify(pub crypto.PublicKey, algo string, data, sig []byte) bool {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if algo != "rsa-sha2-256" {
+			return false
+		}
+		h := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig) == nil
+
+	case *ecdsa.PublicKey:
+		if algo != "ecdsa-sha2-nistp256" {
+			return false
+		}
+		r := reader{buf: sig}
+		rr, ss := r.mpint(), r.mpint()
+		if r.err != nil {
+			return false
+		}
+		h := sha256.Sum256(data)
+		return ecdsa.Verify(k, h[:], rr, ss)
+
+	case ed25519.PublicKey:
+		return algo == "ssh-ed25519" && ed25519.Verify(k, data, sig)
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package sshagent
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+var errShortRead = errors.New("ssh: short read")
+
+// reader decodes the SSH wire format (RFC 4251, section 5).
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 4 {
+		r.err = errShortRead
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.buf)) < n {
+		r.err = errShortRead
+		return nil
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func (r *reader) mpint() *big.Int {
+	return new(big.Int).SetBytes(r.bytes())
+}
+
+// writer encodes the SSH wire format.
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) byte(v byte) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *writer) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *writer) bytes(v []byte) {
+	w.uint32(uint32(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *writer) string(v string) {
+	w.bytes([]byte(v))
+}
+
+func (w *writer) mpint(v *big.Int) {
+	b := v.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	w.bytes(b)
+}